@@ -4,43 +4,51 @@ package assert
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"math"
 	"reflect"
 	"strings"
-	"testing"
 	"time"
+
+	"src.sqlkite.com/tests/internal/failure"
 )
 
+// The part of *testing.T that assert needs. *testing.T, *testing.B and
+// *testing.F all satisfy it, as can a custom harness. Soft, Eventually and
+// Consistently also need it to be comparable, which a pointer always is.
+type TB = failure.TB
+
 // a == b
-func Equal[T comparable](t *testing.T, actual T, expected T) {
+func Equal[T comparable](t TB, actual T, expected T) {
 	t.Helper()
 	if actual != expected {
-		t.Errorf("\nexpected: '%v'\nto equal: '%v'", actual, expected)
-		t.FailNow()
+		Fail(t, "\nexpected: '%v'\nto equal: '%v'", actual, expected)
 	}
 }
 
 // a != b
-func NotEqual[T comparable](t *testing.T, actual T, expected T) {
+func NotEqual[T comparable](t TB, actual T, expected T) {
 	t.Helper()
 	if actual == expected {
-		t.Errorf("\nexpected: '%v'\nto not equal: '%v'", actual, expected)
-		t.FailNow()
+		Fail(t, "\nexpected: '%v'\nto not equal: '%v'", actual, expected)
 	}
 }
 
-func Bytes(t *testing.T, actual []byte, expected []byte) {
+func Bytes(t TB, actual []byte, expected []byte) {
 	t.Helper()
 	if bytes.Compare(actual, expected) != 0 {
-		t.Errorf("\nexpected: '%v'\nto equal: '%v'", actual, expected)
-		t.FailNow()
+		Fail(t, "\nexpected: '%v'\nto equal: '%v'", actual, expected)
 	}
 }
 
 // Two lists are equal (same length & same values in the same order)
-func List[T comparable](t *testing.T, actuals []T, expecteds []T) {
+func List[T comparable](t TB, actuals []T, expecteds []T) {
 	t.Helper()
 	Equal(t, len(actuals), len(expecteds))
+	if len(actuals) != len(expecteds) {
+		// only reachable within Soft
+		return
+	}
 
 	for i, actual := range actuals {
 		Equal(t, actual, expecteds[i])
@@ -48,82 +56,73 @@ func List[T comparable](t *testing.T, actuals []T, expecteds []T) {
 }
 
 // A value is nil
-func Nil(t *testing.T, actual any) {
+func Nil(t TB, actual any) {
 	t.Helper()
 	if actual != nil {
 		v := reflect.ValueOf(actual)
 		kind := v.Kind()
 		if (kind != reflect.Ptr && kind != reflect.Map) || !v.IsNil() {
-			t.Errorf("expected %v to be nil", actual)
-			t.FailNow()
+			Fail(t, "expected %v to be nil", actual)
 		}
 	}
 }
 
 // A value is not nil
-func NotNil(t *testing.T, actual any) {
+func NotNil(t TB, actual any) {
 	t.Helper()
 	if actual == nil {
-		t.Errorf("expected %v to be not nil", actual)
-		t.FailNow()
+		Fail(t, "expected %v to be not nil", actual)
 	}
 }
 
 // A value is true
-func True(t *testing.T, actual bool) {
+func True(t TB, actual bool) {
 	t.Helper()
 	if !actual {
-		t.Error("expected true, got false")
-		t.FailNow()
+		Fail(t, "expected true, got false")
 	}
 }
 
 // A value is false
-func False(t *testing.T, actual bool) {
+func False(t TB, actual bool) {
 	t.Helper()
 	if actual {
-		t.Error("expected false, got true")
-		t.FailNow()
+		Fail(t, "expected false, got true")
 	}
 }
 
 // The string contains the given value
-func StringContains(t *testing.T, actual string, expected string) {
+func StringContains(t TB, actual string, expected string) {
 	t.Helper()
 	if !strings.Contains(actual, expected) {
-		t.Errorf("\nexpected: '%s'\nto contain: '%s'", actual, expected)
-		t.FailNow()
+		Fail(t, "\nexpected: '%s'\nto contain: '%s'", actual, expected)
 	}
 }
 
-func Error(t *testing.T, actual error, expected error) {
+func Error(t TB, actual error, expected error) {
 	t.Helper()
 	if !errors.Is(actual, expected) {
-		t.Errorf("expected '%s' to be '%s'", actual, expected)
-		t.FailNow()
+		Fail(t, "expected '%s' to be '%s'", actual, expected)
 	}
 }
 
-func Nowish(t *testing.T, actual time.Time) {
+func Nowish(t TB, actual time.Time) {
 	t.Helper()
 	diff := math.Abs(time.Now().UTC().Sub(actual).Seconds())
 	if diff > 1 {
-		t.Errorf("expected '%s' to be nowish", actual)
-		t.FailNow()
+		Fail(t, "expected '%s' to be nowish", actual)
 	}
 }
 
-func Timeish(t *testing.T, actual time.Time, expected time.Time) {
+func Timeish(t TB, actual time.Time, expected time.Time) {
 	t.Helper()
 	diff := math.Abs(expected.Sub(actual).Seconds())
 	if diff > 1 {
-		t.Errorf("expected '%s' to be around '%s'", actual, expected)
-		t.FailNow()
+		Fail(t, "expected '%s' to be around '%s'", actual, expected)
 	}
 }
 
-func Fail(t *testing.T, fmt string, args ...interface{}) {
+func Fail(t TB, format string, args ...interface{}) {
 	t.Helper()
-	t.Errorf(fmt, args...)
-	t.FailNow()
+	failure.Report(t, fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,113 @@
+package assert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"src.sqlkite.com/tests"
+	"src.sqlkite.com/tests/factory"
+	"src.sqlkite.com/tests/internal/compare"
+)
+
+// There's at least 1 row in table matching where
+func RowExists(t TB, db tests.TestableDB, table string, where factory.KV) {
+	t.Helper()
+	if n := count(db, table, where); n == 0 {
+		Fail(t, "expected a row in %s where %s", table, describe(where))
+	}
+}
+
+// There's no row in table matching where
+func RowMissing(t TB, db tests.TestableDB, table string, where factory.KV) {
+	t.Helper()
+	if n := count(db, table, where); n != 0 {
+		Fail(t, "expected no row in %s where %s, got %d", table, describe(where), n)
+	}
+}
+
+// table has expected rows (matching where, if given)
+func RowCount(t TB, db tests.TestableDB, table string, expected int, where ...factory.KV) {
+	t.Helper()
+	var w factory.KV
+	if len(where) == 1 {
+		w = where[0]
+	}
+	if actual := count(db, table, w); actual != expected {
+		Fail(t, "\nexpected %s to have %d rows\ngot: %d", table, expected, actual)
+	}
+}
+
+// sql returns a row whose columns match expected (columns of the row not
+// in expected are ignored). Values are compared loosely, so an int matches
+// an int64 and a string matches a []byte.
+func Row(t TB, db tests.TestableDB, sql string, args []any, expected factory.KV) {
+	t.Helper()
+	row := tests.Row(db, sql, args...)
+	if row == nil {
+		Fail(t, "expected a row, got none\n%s", sql)
+		return
+	}
+
+	var diff []string
+	for _, column := range sortedKeys(expected) {
+		e := expected[column]
+		a, exists := row[column]
+		if !exists {
+			diff = append(diff, fmt.Sprintf("  %s: missing (expected '%v')", column, e))
+		} else if !compare.Loose(a, e) {
+			diff = append(diff, fmt.Sprintf("  %s: '%v' != '%v'", column, a, e))
+		}
+	}
+	if len(diff) > 0 {
+		Fail(t, "\nrow doesn't match (actual != expected):\n%s", strings.Join(diff, "\n"))
+	}
+}
+
+func count(db tests.TestableDB, table string, where factory.KV) int {
+	sql, args := whereSQL(where)
+	row := tests.RowAs[struct {
+		Count int `db:"count"`
+	}](db, "select count(*) as count from "+table+sql, args...)
+	return row.Count
+}
+
+func whereSQL(where factory.KV) (string, []any) {
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	var args []any
+	conditions := make([]string, 0, len(where))
+	for _, column := range sortedKeys(where) {
+		value := where[column]
+		if value == nil {
+			conditions = append(conditions, column+" is null")
+			continue
+		}
+		args = append(args, value)
+		conditions = append(conditions, column+" = $"+strconv.Itoa(len(args)))
+	}
+	return " where " + strings.Join(conditions, " and "), args
+}
+
+func describe(kv factory.KV) string {
+	if len(kv) == 0 {
+		return "(anything)"
+	}
+	parts := make([]string, 0, len(kv))
+	for _, k := range sortedKeys(kv) {
+		parts = append(parts, fmt.Sprintf("%s='%v'", k, kv[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys(kv factory.KV) []string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
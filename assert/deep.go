@@ -0,0 +1,437 @@
+package assert
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.sqlkite.com/tests/internal/compare"
+)
+
+type DeepOption func(*deep)
+
+// Fields (of structs) and keys (of maps) with one of these names are
+// ignored, at any depth
+func IgnoreFields(names ...string) DeepOption {
+	return func(d *deep) {
+		for _, name := range names {
+			d.ignore[name] = true
+		}
+	}
+}
+
+// A nil slice/map/pointer is equal to an empty slice/map (or a pointer to
+// a zero value)
+func NilEqualsEmpty() DeepOption {
+	return func(d *deep) {
+		d.nilEqualsEmpty = true
+	}
+}
+
+// Differences are reported relative to path (e.g. "data.users") rather than
+// (root)
+func AtPath(path string) DeepOption {
+	return func(d *deep) {
+		d.root = path
+	}
+}
+
+// Slices (and arrays) are equal if they have the same values in any order
+func Unordered() DeepOption {
+	return func(d *deep) {
+		d.unordered = true
+	}
+}
+
+// actual and expected are deeply equal. Unlike Equal, works with any type
+// (maps, slices, structs with slices, typed.Typed, ...). Numbers are
+// compared by value, so int(1) equals float64(1) (which is what JSON gives
+// us). On failure, every difference is listed with its path.
+func Deep(t TB, actual any, expected any, opts ...DeepOption) {
+	t.Helper()
+
+	d := &deep{ignore: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.compare(d.root, reflect.ValueOf(actual), reflect.ValueOf(expected))
+
+	if len(d.differences) > 0 {
+		Fail(t, "\nexpected values to be equal\n%s", d)
+	}
+}
+
+type deep struct {
+	root           string
+	ignore         map[string]bool
+	unordered      bool
+	nilEqualsEmpty bool
+	differences    []difference
+	visited        map[visit]bool
+}
+
+// A pair of pointers (or maps, or slices) being compared. Like
+// reflect.DeepEqual, seeing the same pair again means we're in a cycle,
+// which is as equal as the rest of the values make it.
+type visit struct {
+	a, e   uintptr
+	length int
+	t      reflect.Type
+}
+
+type difference struct {
+	path     string
+	actual   string
+	expected string
+}
+
+func (d *deep) compare(path string, a reflect.Value, e reflect.Value) {
+	a, e = indirect(a), indirect(e)
+
+	if isNil(a) || isNil(e) {
+		if !d.bothNil(a, e) {
+			d.add(path, a, e)
+		}
+		return
+	}
+
+	if equal, ok := compare.Numbers(a, e); ok {
+		if !equal {
+			d.add(path, a, e)
+		}
+		return
+	}
+
+	// typed.Typed and map[string]any, or []any and []int, are compared
+	// element by element. Only different kinds (or, for the kinds which
+	// can't be walked, different types) are a type mismatch
+	if kind(a) != kind(e) {
+		d.addTypes(path, a, e)
+		return
+	}
+
+	if a.Type() == timeType || e.Type() == timeType {
+		if a.Type() != e.Type() {
+			d.addTypes(path, a, e)
+		} else if !a.CanInterface() || !e.CanInterface() {
+			// an unexported field, the best we can do is its representation
+			if fmt.Sprint(a) != fmt.Sprint(e) {
+				d.add(path, a, e)
+			}
+		} else if !a.Interface().(time.Time).Equal(e.Interface().(time.Time)) {
+			d.add(path, a, e)
+		}
+		return
+	}
+
+	if d.seen(a, e) {
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer:
+		d.compare(path, a.Elem(), e.Elem())
+	case reflect.Struct:
+		if a.Type() != e.Type() {
+			d.addTypes(path, a, e)
+			return
+		}
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+			if d.ignore[name] {
+				continue
+			}
+			d.compare(path+"."+name, a.Field(i), e.Field(i))
+		}
+	case reflect.Map:
+		d.compareMaps(path, a, e)
+	case reflect.Slice, reflect.Array:
+		if isBytes(a) && isBytes(e) {
+			if !bytes.Equal(a.Bytes(), e.Bytes()) {
+				d.add(path, a, e)
+			}
+			return
+		}
+		if d.unordered {
+			d.compareUnordered(path, a, e)
+		} else {
+			d.compareOrdered(path, a, e)
+		}
+	case reflect.String:
+		if a.String() != e.String() {
+			d.add(path, a, e)
+		}
+	case reflect.Bool:
+		if a.Bool() != e.Bool() {
+			d.add(path, a, e)
+		}
+	default:
+		if a.Type() != e.Type() {
+			d.addTypes(path, a, e)
+		} else if !a.CanInterface() || !e.CanInterface() {
+			if fmt.Sprint(a) != fmt.Sprint(e) {
+				d.add(path, a, e)
+			}
+		} else if !reflect.DeepEqual(a.Interface(), e.Interface()) {
+			d.add(path, a, e)
+		}
+	}
+}
+
+func (d *deep) compareMaps(path string, a reflect.Value, e reflect.Value) {
+	// each map is indexed with its own keys, since the key types can differ
+	keys := func(m reflect.Value) map[string]reflect.Value {
+		lookup := make(map[string]reflect.Value, m.Len())
+		for _, k := range m.MapKeys() {
+			lookup[fmt.Sprint(k)] = k
+		}
+		return lookup
+	}
+	aKeys, eKeys := keys(a), keys(e)
+
+	names := make([]string, 0, len(aKeys)+len(eKeys))
+	for name := range aKeys {
+		names = append(names, name)
+	}
+	for name := range eKeys {
+		if _, exists := aKeys[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if d.ignore[name] {
+			continue
+		}
+		ak, aok := aKeys[name]
+		ek, eok := eKeys[name]
+
+		k := ak
+		if !aok {
+			k = ek
+		}
+		p := path + "[" + strconv.Quote(name) + "]"
+		if k.Kind() == reflect.String && isIdentifier(name) {
+			p = path + "." + name
+		}
+
+		switch {
+		case !aok:
+			d.differences = append(d.differences, difference{path: p, actual: "<missing>", expected: format(e.MapIndex(ek))})
+		case !eok:
+			d.differences = append(d.differences, difference{path: p, actual: format(a.MapIndex(ak)), expected: "<missing>"})
+		default:
+			d.compare(p, a.MapIndex(ak), e.MapIndex(ek))
+		}
+	}
+}
+
+func (d *deep) compareOrdered(path string, a reflect.Value, e reflect.Value) {
+	l := a.Len()
+	if e.Len() > l {
+		l = e.Len()
+	}
+	for i := 0; i < l; i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= a.Len():
+			d.differences = append(d.differences, difference{path: p, actual: "<missing>", expected: format(e.Index(i))})
+		case i >= e.Len():
+			d.differences = append(d.differences, difference{path: p, actual: format(a.Index(i)), expected: "<missing>"})
+		default:
+			d.compare(p, a.Index(i), e.Index(i))
+		}
+	}
+}
+
+// Pairs each expected value with an equal actual one. Whatever's left
+// over is reported.
+func (d *deep) compareUnordered(path string, a reflect.Value, e reflect.Value) {
+	used := make([]bool, a.Len())
+	for i := 0; i < e.Len(); i++ {
+		found := false
+		for j := 0; j < a.Len(); j++ {
+			if used[j] {
+				continue
+			}
+			sub := &deep{ignore: d.ignore, unordered: true, nilEqualsEmpty: d.nilEqualsEmpty}
+			sub.compare("", a.Index(j), e.Index(i))
+			if len(sub.differences) == 0 {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			d.differences = append(d.differences, difference{path: path + "[" + strconv.Itoa(i) + "]", actual: "<missing>", expected: format(e.Index(i))})
+		}
+	}
+	for j, u := range used {
+		if !u {
+			d.differences = append(d.differences, difference{path: path + "[" + strconv.Itoa(j) + "]", actual: format(a.Index(j)), expected: "<unexpected>"})
+		}
+	}
+}
+
+func (d *deep) seen(a reflect.Value, e reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+	default:
+		return false
+	}
+	if a.Kind() != e.Kind() {
+		return false
+	}
+	v := visit{a: a.Pointer(), e: e.Pointer(), t: a.Type()}
+	if a.Kind() == reflect.Slice {
+		v.length = a.Len()
+	}
+	if d.visited == nil {
+		d.visited = make(map[visit]bool)
+	}
+	if d.visited[v] {
+		return true
+	}
+	d.visited[v] = true
+	return false
+}
+
+func (d *deep) bothNil(a reflect.Value, e reflect.Value) bool {
+	an, en := isNil(a), isNil(e)
+	if an && en {
+		return true
+	}
+	if !d.nilEqualsEmpty {
+		return false
+	}
+	return (an && isEmpty(e)) || (en && isEmpty(a))
+}
+
+// Like add, but the values are of different types, so include them
+func (d *deep) addTypes(path string, a reflect.Value, e reflect.Value) {
+	if path == "" {
+		path = "(root)"
+	}
+	d.differences = append(d.differences, difference{
+		path:     path,
+		actual:   format(a) + " (" + a.Type().String() + ")",
+		expected: format(e) + " (" + e.Type().String() + ")",
+	})
+}
+
+func (d *deep) add(path string, a reflect.Value, e reflect.Value) {
+	if path == "" {
+		path = "(root)"
+	}
+	d.differences = append(d.differences, difference{
+		path:     path,
+		actual:   format(a),
+		expected: format(e),
+	})
+}
+
+// One line per difference: path: actual != expected. Colorized when
+// stdout is a terminal and NO_COLOR isn't set.
+func (d *deep) String() string {
+	red, green, reset := "", "", ""
+	if colorize() {
+		red, green, reset = "\x1b[31m", "\x1b[32m", "\x1b[0m"
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("(" + green + "actual" + reset + " != " + red + "expected" + reset + ")\n")
+	for _, diff := range d.differences {
+		sb.WriteString(diff.path + ": " + green + diff.actual + reset + " != " + red + diff.expected + reset + "\n")
+	}
+	return sb.String()
+}
+
+func colorize() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Unwraps interfaces (and any values within them)
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isNil(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+func isEmpty(v reflect.Value) bool {
+	v = indirect(v)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.Pointer:
+		return v.IsNil() || v.Elem().IsZero()
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Slices and arrays are the same thing to Deep
+func kind(v reflect.Value) reflect.Kind {
+	if k := v.Kind(); k != reflect.Array {
+		return k
+	}
+	return reflect.Slice
+}
+
+func isBytes(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8
+}
+
+func format(v reflect.Value) string {
+	v = indirect(v)
+	if isNil(v) {
+		return "nil"
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	if v.CanInterface() {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
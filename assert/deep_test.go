@@ -0,0 +1,108 @@
+package assert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"src.sqlkite.com/tests/internal/failure"
+	"src.sqlkite.com/utils/typed"
+)
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+// a cycle of n nodes, the last with value last
+func cycle(n int, last int) *node {
+	head := &node{Value: 1}
+	tail := head
+	for i := 1; i < n; i++ {
+		tail.Next = &node{Value: 1}
+		tail = tail.Next
+	}
+	tail.Value = last
+	tail.Next = head
+	return head
+}
+
+type event struct {
+	at time.Time
+}
+
+func TestDeepEqual(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		actual   any
+		expected any
+		opts     []DeepOption
+	}{
+		{name: "typed.Typed and map", actual: typed.Typed{"a": 1}, expected: map[string]any{"a": 1}},
+		{name: "nested typed.Typed", actual: map[string]any{"a": typed.Typed{"b": []any{1.0, 2.0}}}, expected: typed.Typed{"a": map[string]any{"b": []int{1, 2}}}},
+		{name: "[]any and []int", actual: []any{1, 2}, expected: []int{1, 2}},
+		{name: "array and slice", actual: [2]int{1, 2}, expected: []int64{1, 2}},
+		{name: "numbers", actual: int64(812345678901234567), expected: uint64(812345678901234567)},
+		{name: "json number", actual: 3.0, expected: 3},
+		{name: "named string", actual: time.Month(1), expected: 1},
+		{name: "bytes", actual: []byte("abc"), expected: []byte("abc")},
+		{name: "time", actual: now, expected: now.UTC()},
+		{name: "pointer", actual: &struct{ A int }{1}, expected: &struct{ A int }{1}},
+		{name: "cycle", actual: cycle(3, 2), expected: cycle(3, 2)},
+		{name: "unexported time", actual: event{at: now}, expected: event{at: now}},
+		{name: "nil", actual: nil, expected: nil},
+		{name: "nil equals empty", actual: []int(nil), expected: []int{}, opts: []DeepOption{NilEqualsEmpty()}},
+		{name: "unordered", actual: []any{1, "b"}, expected: []any{"b", 1}, opts: []DeepOption{Unordered()}},
+		{name: "ignored", actual: map[string]any{"id": 1, "a": 2}, expected: map[string]any{"id": 9, "a": 2}, opts: []DeepOption{IgnoreFields("id")}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if message, failed := failure.Capture(t, func() { Deep(t, tc.actual, tc.expected, tc.opts...) }); failed {
+				t.Fatalf("expected equal, got:%s", message)
+			}
+		})
+	}
+}
+
+func TestDeepDifferences(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	cases := []struct {
+		name     string
+		actual   any
+		expected any
+		lines    []string
+	}{
+		{name: "typed.Typed value", actual: typed.Typed{"a": 1}, expected: map[string]any{"a": 2}, lines: []string{".a: 1 != 2"}},
+		{name: "slice element", actual: []any{1, 2}, expected: []int{1, 3}, lines: []string{"[1]: 2 != 3"}},
+		{name: "missing", actual: []int{1}, expected: []int{1, 2}, lines: []string{"[1]: <missing> != 2"}},
+		{name: "missing key", actual: map[string]any{}, expected: map[string]any{"a b": 1}, lines: []string{`["a b"]: <missing> != 1`}},
+		{name: "integers", actual: int64(812345678901234567), expected: int64(812345678901234560), lines: []string{"(root): 812345678901234567 != 812345678901234560"}},
+		{name: "kinds", actual: map[string]any{"a": "1"}, expected: map[string]any{"a": 1}, lines: []string{`.a: "1" (string) != 1 (int)`}},
+		{name: "map and slice", actual: typed.Typed{}, expected: []int{}, lines: []string{"(root): map[] (typed.Typed) != [] ([]int)"}},
+		{name: "cycle", actual: cycle(2, 2), expected: cycle(2, 3), lines: []string{".Next.Value: 2 != 3"}},
+		{name: "struct types", actual: struct{ A int }{1}, expected: struct{ B int }{1}, lines: []string{"(root): {1} (struct { A int }) != {1} (struct { B int })"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			message, failed := failure.Capture(t, func() { Deep(t, tc.actual, tc.expected) })
+			if !failed {
+				t.Fatal("expected a difference")
+			}
+			for _, line := range tc.lines {
+				if !strings.Contains(message, "\n"+line+"\n") {
+					t.Fatalf("expected %q in:%s", line, message)
+				}
+			}
+		})
+	}
+}
+
+func TestDeepUnexportedTime(t *testing.T) {
+	message, failed := failure.Capture(t, func() { Deep(t, event{at: time.Unix(0, 0)}, event{at: time.Unix(1, 0)}) })
+	if !failed || !strings.Contains(message, "\n.at: ") {
+		t.Fatalf("expected a difference at .at, got:%s", message)
+	}
+}
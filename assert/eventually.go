@@ -0,0 +1,95 @@
+package assert
+
+import (
+	"fmt"
+	"time"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+// fn returns true within timeout, checked every interval. An assertion
+// that fails within fn counts as false (rather than failing the test), so
+// fn can use them directly. Replaces the time.Sleep before an assertion on
+// something that happens in the background:
+//
+//	assert.Eventually(t, func() bool {
+//		assert.Equal(t, tests.Row(db, "select count(*) as n from log").Int("n"), 3)
+//		return true
+//	}, time.Second, 10*time.Millisecond)
+func Eventually(t TB, fn func() bool, timeout time.Duration, interval time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, message := attempt(t, fn)
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			Fail(t, "\ncondition not met within %s%s", timeout, lastFailure(message))
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Like Eventually, but fn also returns a value, which is returned once fn
+// returns true. On failure, the last value fn returned is included:
+//
+//	row := assert.EventuallyValue(t, func() (typed.Typed, bool) {
+//		row := tests.Row(db, "select * from users where id = $1", id)
+//		return row, row != nil
+//	}, time.Second, 10*time.Millisecond)
+func EventuallyValue[T any](t TB, fn func() (T, bool), timeout time.Duration, interval time.Duration) T {
+	t.Helper()
+	var value T
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, message := attempt(t, func() bool {
+			var ok bool
+			value, ok = fn()
+			return ok
+		})
+		if ok {
+			return value
+		}
+		if time.Now().After(deadline) {
+			Fail(t, "\ncondition not met within %s\nlast value: %v%s", timeout, value, lastFailure(message))
+			return value
+		}
+		time.Sleep(interval)
+	}
+}
+
+// fn keeps returning true for the duration, checked every interval. An
+// assertion that fails within fn counts as false.
+func Consistently(t TB, fn func() bool, duration time.Duration, interval time.Duration) {
+	t.Helper()
+	start := time.Now()
+	deadline := start.Add(duration)
+	for {
+		ok, message := attempt(t, fn)
+		if !ok {
+			Fail(t, "\ncondition stopped holding after %s%s", time.Since(start).Round(time.Millisecond), lastFailure(message))
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Runs fn, turning a failed assertion into false (and its message)
+func attempt(t TB, fn func() bool) (ok bool, message string) {
+	message, failed := failure.Capture(t, func() {
+		ok = fn()
+	})
+	return ok && !failed, message
+}
+
+func lastFailure(message string) string {
+	if message == "" {
+		return ""
+	}
+	return fmt.Sprintf("\nlast failure: %s", message)
+}
@@ -0,0 +1,106 @@
+package assert
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+func TestEventuallyPasses(t *testing.T) {
+	polls := 0
+	message, failed := failure.Capture(t, func() {
+		Eventually(t, func() bool {
+			polls++
+			Equal(t, polls, 3)
+			return true
+		}, time.Second, time.Millisecond)
+	})
+	if failed {
+		t.Fatalf("expected Eventually to pass, got:\n%s", message)
+	}
+	if polls != 3 {
+		t.Fatalf("expected 3 polls, got %d", polls)
+	}
+}
+
+func TestEventuallyTimesOut(t *testing.T) {
+	polls := 0
+	message, failed := failure.Capture(t, func() {
+		Eventually(t, func() bool {
+			polls++
+			Equal(t, polls, -1)
+			return true
+		}, 20*time.Millisecond, time.Millisecond)
+	})
+	if !failed {
+		t.Fatal("expected Eventually to fail")
+	}
+	if !strings.Contains(message, "condition not met within 20ms") || !strings.Contains(message, "last failure: \nexpected: '"+strconv.Itoa(polls)+"'") {
+		t.Fatalf("expected the last failure, got:\n%s", message)
+	}
+}
+
+func TestEventuallyValue(t *testing.T) {
+	polls := 0
+	var value int
+	message, failed := failure.Capture(t, func() {
+		value = EventuallyValue(t, func() (int, bool) {
+			polls++
+			return polls * 10, polls == 3
+		}, time.Second, time.Millisecond)
+	})
+	if failed || value != 30 {
+		t.Fatalf("expected 30, got %d:\n%s", value, message)
+	}
+
+	message, failed = failure.Capture(t, func() {
+		EventuallyValue(t, func() (string, bool) {
+			return "pending", false
+		}, 10*time.Millisecond, time.Millisecond)
+	})
+	if !failed || !strings.Contains(message, "last value: pending") {
+		t.Fatalf("expected the last value, got:\n%s", message)
+	}
+}
+
+func TestConsistently(t *testing.T) {
+	polls := 0
+	message, failed := failure.Capture(t, func() {
+		Consistently(t, func() bool {
+			polls++
+			return true
+		}, 10*time.Millisecond, time.Millisecond)
+	})
+	if failed || polls < 2 {
+		t.Fatalf("expected Consistently to pass after a few polls (got %d):\n%s", polls, message)
+	}
+
+	polls = 0
+	message, failed = failure.Capture(t, func() {
+		Consistently(t, func() bool {
+			polls++
+			Equal(t, polls < 3, true)
+			return true
+		}, time.Second, time.Millisecond)
+	})
+	if !failed || polls != 3 {
+		t.Fatalf("expected Consistently to fail on the 3rd poll, got %d", polls)
+	}
+	if !strings.Contains(message, "condition stopped holding after") || !strings.Contains(message, "last failure: \nexpected: 'false'") {
+		t.Fatalf("expected the failure, got:\n%s", message)
+	}
+}
+
+func TestEventuallyPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("expected the panic to propagate, got %v", r)
+		}
+	}()
+	Eventually(t, func() bool {
+		panic("boom")
+	}, time.Second, time.Millisecond)
+}
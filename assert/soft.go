@@ -0,0 +1,42 @@
+package assert
+
+import (
+	"strings"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+// Runs fn, collecting every assertion failure for t rather than stopping
+// at the first one. They're reported together once fn returns (or, if fn
+// panics, before the panic carries on). Nothing changes within fn, it's
+// the same assert.Equal(t, ...) calls:
+//
+//	assert.Soft(t, func() {
+//		assert.Equal(t, user.String("name"), "leto")
+//		assert.Equal(t, user.Int("age"), 3000)
+//	})
+func Soft(t TB, fn func()) {
+	t.Helper()
+
+	var failures []string
+	finished := false
+	defer func() {
+		// fn panicked (or stopped the test), which carries on, but what had
+		// failed before it shouldn't be lost
+		if !finished && len(failures) > 0 {
+			t.Errorf("%d assertions failed before stopping:\n%s", len(failures), strings.Join(failures, "\n"))
+		}
+	}()
+
+	func() {
+		defer failure.Push(t, func(message string) {
+			failures = append(failures, message)
+		})()
+		fn()
+	}()
+	finished = true
+
+	if len(failures) > 0 {
+		Fail(t, "%d assertions failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+}
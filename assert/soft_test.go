@@ -0,0 +1,62 @@
+package assert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+// Records what's reported to it directly (rather than through a handler)
+type recorder struct {
+	messages []string
+}
+
+func (r *recorder) Helper()  {}
+func (r *recorder) FailNow() {}
+func (r *recorder) Errorf(format string, args ...any) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func TestSoftReportsEveryFailure(t *testing.T) {
+	message, failed := failure.Capture(t, func() {
+		Soft(t, func() {
+			Equal(t, 11, 22)
+			Equal(t, "a", "a")
+			Equal(t, "bb", "cc")
+		})
+	})
+	if !failed {
+		t.Fatal("expected Soft to fail")
+	}
+	if !strings.HasPrefix(message, "2 assertions failed:\n") || !strings.Contains(message, "22") || !strings.Contains(message, "cc") {
+		t.Fatalf("expected both failures, got:\n%s", message)
+	}
+}
+
+func TestSoftPasses(t *testing.T) {
+	if message, failed := failure.Capture(t, func() {
+		Soft(t, func() { Equal(t, 1, 1) })
+	}); failed {
+		t.Fatalf("expected Soft to pass, got:\n%s", message)
+	}
+}
+
+func TestSoftPanic(t *testing.T) {
+	r := &recorder{}
+	func() {
+		defer func() {
+			if recover() != "oops" {
+				t.Fatal("expected the panic to carry on")
+			}
+		}()
+		Soft(r, func() {
+			Equal(r, 1, 2)
+			panic("oops")
+		})
+	}()
+	if len(r.messages) != 1 || !strings.HasPrefix(r.messages[0], "1 assertions failed before stopping:\n") {
+		t.Fatalf("expected the earlier failure to be reported, got %v", r.messages)
+	}
+}
@@ -12,16 +12,15 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
-	"testing"
 )
 
 type v struct {
-	t      *testing.T
+	t      TB
 	json   []byte
 	errors []map[string]any
 }
 
-func Validation(t *testing.T, result any) *v {
+func Validation(t TB, result any) *v {
 	e1 := reflect.ValueOf(result).MethodByName("Errors").Call(nil)[0]
 	data, err := json.MarshalIndent(e1.Interface(), "", " ")
 	if err != nil {
@@ -93,8 +92,7 @@ func (v *v) Field(expectedField string, meta any, data ...map[string]any) *v {
 	err += fmt.Sprintf("  code=%d\n", expectedCode)
 	err += fmt.Sprintf("  data=%v\n\n", expectedData)
 	err += fmt.Sprintf("got: %s", string(v.json))
-	t.Error(err)
-	t.FailNow()
+	Fail(t, "%s", err)
 	return v
 }
 
@@ -120,8 +118,7 @@ func (v *v) FieldMessage(expectedField string, expectedMessage string) *v {
 	err += fmt.Sprintf("  field=%s\n", expectedField)
 	err += fmt.Sprintf("  message=%s\n", expectedMessage)
 	err += fmt.Sprintf("got: %s", string(v.json))
-	t.Error(err)
-	t.FailNow()
+	Fail(t, "%s", err)
 	return v
 }
 
@@ -136,8 +133,7 @@ func (v *v) FieldsHaveNoErrors(noFields ...string) *v {
 		}
 		for _, noField := range noFields {
 			if v.isCorrectField(field, error["indexes"], noField) {
-				t.Errorf("Expected no error for field '%s', but got:\n%v", field, error)
-				t.FailNow()
+				Fail(t, "Expected no error for field '%s', but got:\n%v", field, error)
 			}
 		}
 	}
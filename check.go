@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+// Upper bound on how many times a counterexample is re-run while shrinking it
+const shrinkBudget = 1000
+
+// Runs property against 100 (or runs[0]) generated inputs. A counterexample
+// is a run where property returns false, panics or fails an assertion
+// (assert.Equal(t, ...) and friends don't fail the test while the property
+// runs). The first counterexample is shrunk (shorter strings, smaller
+// numbers) and then re-run one last time, outside of Check, to report it.
+// The shrunk values are part of the failure; SQLKITE_TEST_SEED replays the
+// original, unshrunk, run.
+func Check(t *testing.T, property func(g Generator) bool, runs ...int) {
+	t.Helper()
+
+	n := 100
+	if len(runs) == 1 {
+		n = runs[0]
+	}
+
+	c := findCounterexample(t, property, n)
+	if c == nil {
+		return
+	}
+	t.Logf("counterexample found on run %d, shrunk %d times:\n%s", c.run, c.steps, c)
+
+	ok, panicked := replay(property, c.draws)
+	switch {
+	case panicked != nil:
+		failure.Report(t, fmt.Sprintf("property panicked (%v) for:\n%s", panicked, c))
+	case ok:
+		failure.Report(t, "counterexample didn't reproduce, is the property deterministic?")
+	default:
+		failure.Report(t, fmt.Sprintf("property returned false for:\n%s", c))
+	}
+}
+
+// Runs property with draws, outside of any failure capture, so that a
+// failed assertion fails the test with its own message. A panic is
+// recovered, so that it can be reported with the values which caused it.
+func replay(property func(g Generator) bool, draws []int64) (ok bool, panicked any) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok, panicked = false, r
+		}
+	}()
+	return property(Generator{src: &source{replaying: true, replay: draws}}), nil
+}
+
+type counterexample struct {
+	// 1-based
+	run   int
+	steps int
+	draws []int64
+	// what the property was given when replaying draws
+	values []generated
+}
+
+func (c *counterexample) String() string {
+	sb := strings.Builder{}
+	for _, v := range c.values {
+		fmt.Fprintf(&sb, "  %s: %#v\n", v.name, v.value)
+	}
+	return sb.String()
+}
+
+// The first counterexample, shrunk, or nil if property held for every run
+func findCounterexample(t *testing.T, property func(g Generator) bool, runs int) *counterexample {
+	r := rand.New(rand.NewSource(testSeed(t)))
+	for i := 0; i < runs; i++ {
+		src := &source{r: rand.New(rand.NewSource(r.Int63())), record: true}
+		if holds(t, property, src) {
+			continue
+		}
+
+		draws, steps := shrink(t, property, src.draws)
+		replay := &source{replaying: true, replay: draws}
+		holds(t, property, replay)
+		return &counterexample{run: i + 1, steps: steps, draws: draws, values: replay.values}
+	}
+	return nil
+}
+
+func holds(t *testing.T, property func(g Generator) bool, src *source) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+
+	_, failed := failure.Capture(t, func() {
+		ok = property(Generator{src: src})
+	})
+	return ok && !failed
+}
+
+// Shrinks by making the recorded draws shorter and smaller. Since lengths
+// and numbers are drawn directly, smaller draws mean shorter strings and
+// smaller numbers.
+func shrink(t *testing.T, property func(g Generator) bool, draws []int64) ([]int64, int) {
+	budget := shrinkBudget
+	fails := func(candidate []int64) bool {
+		if budget == 0 {
+			return false
+		}
+		budget--
+		src := &source{replaying: true, replay: candidate, record: true}
+		if holds(t, property, src) {
+			return false
+		}
+		// only keep what the property actually used. That's not always
+		// smaller than what we had, since draws past the end of the replay
+		// are 0s (which would then be removed again, forever)
+		if !simpler(src.draws, draws) {
+			return false
+		}
+		draws = src.draws
+		return true
+	}
+
+	steps := 0
+	for improved := true; improved && budget > 0; {
+		improved = false
+
+		// remove chunks of draws
+		for size := 8; size > 0; size /= 2 {
+			for i := 0; i+size <= len(draws); {
+				candidate := append(append([]int64{}, draws[:i]...), draws[i+size:]...)
+				if fails(candidate) {
+					steps++
+					improved = true
+				} else {
+					i++
+				}
+			}
+		}
+
+		// make each draw as small as possible
+		for i := 0; i < len(draws); i++ {
+			lo, hi := int64(0), draws[i]
+			for lo < hi {
+				mid := lo + (hi-lo)/2
+				if fails(smaller(draws, i, mid, true)) || fails(smaller(draws, i, mid, false)) {
+					steps++
+					improved = true
+					if i >= len(draws) {
+						break
+					}
+					hi = draws[i]
+				} else {
+					lo = mid + 1
+				}
+			}
+		}
+	}
+	return draws, steps
+}
+
+// draws with draws[i] = value. When the draw is a length, the values drawn
+// for the removed items need to go too (else they're read as whatever is
+// generated next), so dropFollowing also removes that many following draws.
+func smaller(draws []int64, i int, value int64, dropFollowing bool) []int64 {
+	candidate := append([]int64{}, draws[:i+1]...)
+	candidate[i] = value
+
+	rest := draws[i+1:]
+	if dropFollowing {
+		drop := int(draws[i] - value)
+		if drop > len(rest) {
+			drop = len(rest)
+		}
+		rest = rest[drop:]
+	}
+	return append(candidate, rest...)
+}
+
+// a is shorter than b or, for the same length, has a smaller draw at the
+// first difference
+func simpler(a []int64, b []int64) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
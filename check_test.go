@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/tests/internal/failure"
+)
+
+func TestCheckShrinks(t *testing.T) {
+	cases := []struct {
+		name     string
+		property func(g Generator) bool
+		expected []any
+	}{
+		{
+			name:     "string length",
+			property: func(g Generator) bool { return len(g.String()) < 5 },
+			expected: []any{"aaaaa"},
+		},
+		{
+			name:     "string contents",
+			property: func(g Generator) bool { return !strings.ContainsAny(g.String(), "xyz") },
+			expected: []any{"x"},
+		},
+		{
+			name:     "int",
+			property: func(g Generator) bool { return g.Int() < 100 },
+			expected: []any{100},
+		},
+		{
+			name: "panic",
+			property: func(g Generator) bool {
+				if g.Int(1000) > 10 {
+					panic("too big")
+				}
+				return true
+			},
+			expected: []any{11},
+		},
+		{
+			name: "dependent values",
+			property: func(g Generator) bool {
+				s := g.String(1, 50)
+				n := g.Int(100)
+				return n < len(s)
+			},
+			expected: []any{"a", 1},
+		},
+		{
+			name: "list",
+			property: func(g Generator) bool {
+				for i, l := 0, g.Int(20); i < l; i++ {
+					if g.Int(1000) >= 500 {
+						return false
+					}
+				}
+				return true
+			},
+			expected: []any{1, 500},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := findCounterexample(t, tc.property, 200)
+			if c == nil {
+				t.Fatal("no counterexample found")
+			}
+			actual := make([]any, len(c.values))
+			for i, v := range c.values {
+				actual[i] = v.value
+			}
+			if len(actual) != len(tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+			for i := range actual {
+				if actual[i] != tc.expected[i] {
+					t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckNoCounterexample(t *testing.T) {
+	c := findCounterexample(t, func(g Generator) bool { return len(g.String(0, 10)) <= 10 }, 100)
+	if c != nil {
+		t.Fatalf("unexpected counterexample:\n%s", c)
+	}
+}
+
+func TestCheckReportsShrunkValues(t *testing.T) {
+	message, failed := failure.Capture(t, func() {
+		Check(t, func(g Generator) bool {
+			return g.Int(1000) < 10 || g.Email() == ""
+		})
+	})
+	if !failed {
+		t.Fatal("expected Check to fail")
+	}
+	if !strings.Contains(message, "Int: 10\n") || !strings.Contains(message, `Email: "a@aaa.test"`) {
+		t.Fatalf("expected the shrunk values, got:\n%s", message)
+	}
+}
+
+func TestCheckReportsPanics(t *testing.T) {
+	message, failed := failure.Capture(t, func() {
+		Check(t, func(g Generator) bool {
+			if g.Int(1000) > 10 {
+				panic("too big")
+			}
+			return true
+		})
+	})
+	if !failed {
+		t.Fatal("expected Check to fail")
+	}
+	if !strings.Contains(message, "property panicked (too big) for:\n") || !strings.Contains(message, "Int: 11\n") {
+		t.Fatalf("expected the panic and the shrunk value, got:\n%s", message)
+	}
+}
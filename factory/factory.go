@@ -5,8 +5,14 @@ A helper to build test factories
 */
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
 	"time"
 
 	"src.sqlkite.com/utils/typed"
@@ -17,57 +23,581 @@ type SQLStorage interface {
 	Placeholder(i int) string
 }
 
+// Can read rows back, which is needed to introspect a table's schema and
+// for Returning()
+type Querier interface {
+	Placeholder(i int) string
+	RowsToMap(sql string, args ...any) ([]typed.Typed, error)
+}
+
+type QueryStorage interface {
+	SQLStorage
+	Querier
+}
+
+// The storage used by tables which aren't bound to one (see Factory,
+// Table.With and Table.For), unless a running test registered its own with
+// Use
 var DB SQLStorage
 
+// Storage registered for a test with Use
+var scoped sync.Map
+
+// Largest number of values a multi-row insert will have. This is sqlite's
+// SQLITE_MAX_VARIABLE_NUMBER prior to 3.32 (postgres allows 65535)
+const maxParameters = 999
+
 type Table struct {
-	Truncate func() Table
-	Insert   func(args ...any) typed.Typed
+	name    string
+	builder func(KV) KV
+	pks     []string
+
+	// nil to use DB
+	db SQLStorage
+
+	// NewTable: the columns, as returned by builder(KV{})
+	keys []string
+
+	// NewTableFromSchema: the table's actual columns
+	schema []Column
+
+	deleteSQL    string
+	returning    bool
+	associations []association
+	traits       map[string]KV
+
+	// shared by every copy of the table, see KV.Seq
+	sequence *int64
+}
+
+type association struct {
+	column string
+	parent Table
+	key    string
 }
 
+// Tables created from a Factory use its storage rather than DB
+type Factory struct {
+	db SQLStorage
+}
+
+func New(db SQLStorage) Factory {
+	return Factory{db: db}
+}
+
+// db is used, for the rest of the test, by tables accessed through For(t)
+// and by tables which aren't bound to a storage. The latter is only
+// unambiguous when a single test (or a test and its subtests, in which case
+// the innermost wins) is using storage: tests which Use storage in parallel
+// must access tables through For(t), else the insert panics.
+func Use(t testing.TB, db SQLStorage) {
+	scoped.Store(t, db)
+	t.Cleanup(func() {
+		scoped.Delete(t)
+	})
+}
+
+// Same as Factory.NewTable, using DB
 func NewTable(name string, builder func(KV) KV, pks ...string) Table {
+	return Factory{}.NewTable(name, builder, pks...)
+}
+
+// Same as Factory.NewTableFromSchema, using DB
+func NewTableFromSchema(name string, builder func(KV) KV, pks ...string) Table {
+	return Factory{}.NewTableFromSchema(name, builder, pks...)
+}
+
+// The columns are whatever builder(KV{}) returns, so the builder should
+// always return every column (even if it's nil). They're inserted in
+// alphabetical order.
+func (f Factory) NewTable(name string, builder func(KV) KV, pks ...string) Table {
 	obj := builder(KV{})
 	keys := make([]string, len(obj))
-	placeholders := make([]string, len(obj))
 
 	i := 0
 	for k := range obj {
 		keys[i] = k
-		placeholders[i] = DB.Placeholder(i)
 		i++
 	}
+	// map order is random, we want the same SQL every time
+	sort.Strings(keys)
 
-	insertSQL := "insert into " + name + " (" + strings.Join(keys, ",") + ")"
-	insertSQL += "\nvalues (" + strings.Join(placeholders, ",") + ")"
-	if len(pks) > 0 {
-		insertSQL += "\non conflict (" + strings.Join(pks, ",") + ") do update set "
-		insertSQL += keys[0] + " = excluded." + keys[0]
-		for _, k := range keys[1:] {
-			insertSQL += ", " + k + " = excluded." + k
-		}
+	return Table{
+		db:        f.db,
+		name:      name,
+		builder:   builder,
+		pks:       pks,
+		keys:      keys,
+		deleteSQL: "delete from " + name,
+		sequence:  new(int64),
+	}
+}
+
+// The columns are read from the database (so the storage must be a
+// QueryStorage) and inserted in declaration order.
+// builder is checked for unknown columns here, and each row is validated
+// against the schema when it's built or inserted: unknown columns and
+// missing NOT NULL columns (without a default) panic. Since that's after
+// associations and args are applied, a NOT NULL foreign key can be left to
+// BelongsTo or the caller. Nil values for a column with a default are not
+// inserted, so the default applies.
+func (f Factory) NewTableFromSchema(name string, builder func(KV) KV, pks ...string) Table {
+	storage := f.db
+	if storage == nil {
+		storage = DB
+	}
+	db, ok := storage.(QueryStorage)
+	if !ok {
+		panic("factory.NewTableFromSchema requires the storage to implement factory.QueryStorage")
+	}
+
+	schema, err := Columns(db, name)
+	if err != nil {
+		panic(err)
+	}
+	if len(schema) == 0 {
+		panic("factory.NewTableFromSchema: table " + name + " does not exist")
 	}
 
-	deleteSQL := "delete from " + name
+	t := Table{
+		db:        f.db,
+		name:      name,
+		builder:   builder,
+		pks:       pks,
+		schema:    schema,
+		deleteSQL: "delete from " + name,
+		sequence:  new(int64),
+	}
+	t.unknownColumns(builder(KV{}))
+	return t
+}
 
-	t := Table{}
+// The table, using db rather than DB (or its Factory's storage)
+func (t Table) With(db SQLStorage) Table {
+	t.db = db
+	return t
+}
 
-	t.Truncate = func() Table {
-		DB.MustExec(deleteSQL)
-		return t
+// The table, using the storage registered for tb with Use (if there is one)
+func (t Table) For(tb testing.TB) Table {
+	if db, ok := scoped.Load(tb); ok {
+		return t.With(db.(SQLStorage))
 	}
+	return t
+}
+
+func (t Table) Truncate() Table {
+	t.storage().MustExec(t.deleteSQL)
+	return t
+}
 
-	t.Insert = func(args ...any) typed.Typed {
-		obj := builder(ToKV(args))
-		values := make([]any, len(obj))
-		for i, k := range keys {
-			values[i] = obj[k]
+// Insert uses "returning *" and the row it returns is the row as stored,
+// including the values generated by the database (serial ids, defaults,
+// triggers...). Nil columns aren't inserted, so their default applies. The
+// storage must be a QueryStorage and, for sqlite, at least 3.35. InsertMany
+// and InsertAll are unaffected.
+func (t Table) Returning() Table {
+	db, ok := t.storage().(QueryStorage)
+	if !ok {
+		panic("factory.Table.Returning requires the storage to implement factory.QueryStorage")
+	}
+	if isSQLite(db) {
+		rows, err := db.RowsToMap("select sqlite_version() as version")
+		if err != nil {
+			panic(err)
+		}
+		if !atLeast(rows[0].String("version"), 3, 35) {
+			panic("factory.Table.Returning requires sqlite 3.35+, got " + rows[0].String("version"))
 		}
-		DB.MustExec(insertSQL, values...)
-		return typed.Typed(obj)
 	}
+	t.returning = true
+	return t
+}
 
+// column references parent's key. When column isn't given to Insert, a
+// parent row is inserted and its key used. An existing parent can be given
+// instead of a key, e.g. Projects.Insert("user_id", user) where user is a
+// typed.Typed or KV.
+func (t Table) BelongsTo(column string, parent Table, key string) Table {
+	associations := make([]association, len(t.associations), len(t.associations)+1)
+	copy(associations, t.associations)
+	t.associations = append(associations, association{column: column, parent: parent, key: key})
+	return t
+}
+
+// A named preset of args. Traits are given to Insert (or InsertMany) before
+// any other args, e.g. Users.Insert("admin", "name", "leto"), and explicit
+// args take precedence over the trait's. A trait can't have the same name
+// as a column.
+func (t Table) Trait(name string, args ...any) Table {
+	if t.hasColumn(name) {
+		panic("factory: trait " + name + " has the same name as a column of " + t.name)
+	}
+
+	traits := make(map[string]KV, len(t.traits)+1)
+	for k, v := range t.traits {
+		traits[k] = v
+	}
+	traits[name] = ToKV(args)
+	t.traits = traits
 	return t
 }
 
+func (t Table) Insert(args ...any) typed.Typed {
+	obj := t.build(t.toKV(args), inserting)
+	keys := t.columns(obj)
+
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i] = obj[k]
+	}
+
+	db := t.storage()
+	if !t.returning {
+		db.MustExec(t.sql(keys), values...)
+		return typed.Typed(obj)
+	}
+
+	// leave nil columns to the database
+	present := keys[:0:0]
+	values = values[:0]
+	for _, k := range keys {
+		if v := obj[k]; v != nil {
+			present = append(present, k)
+			values = append(values, v)
+		}
+	}
+
+	rows, err := db.(QueryStorage).RowsToMap(t.sql(present)+"\nreturning *", values...)
+	if err != nil {
+		panic(err)
+	}
+	if len(rows) == 1 {
+		for k, v := range rows[0] {
+			obj[k] = v
+		}
+	}
+	return typed.Typed(obj)
+}
+
+// Inserts n rows, args(i) being the args for the i-th row (as if passed to
+// Insert). Rows are batched into multi-row inserts.
+func (t Table) InsertMany(n int, args func(i int) []any) []typed.Typed {
+	rows := make([]KV, n)
+	for i := range rows {
+		if args == nil {
+			rows[i] = KV{}
+		} else {
+			rows[i] = t.toKV(args(i))
+		}
+	}
+	return t.InsertAll(rows)
+}
+
+// Inserts a row for each KV (as if passed to Insert). Rows are batched into
+// multi-row inserts. For a table with pks, a row with the same pk as an
+// earlier row of the batch starts a new batch (postgres and cockroach won't
+// upsert the same row twice in one statement), so, like with Insert, the
+// last one wins.
+func (t Table) InsertAll(rows []KV) []typed.Typed {
+	db := t.storage()
+	inserted := make([]typed.Typed, len(rows))
+
+	var keys []string
+	var values []any
+	batched := 0
+	pks := make(map[string]bool)
+
+	flush := func() {
+		if batched > 0 {
+			db.MustExec(insertSQL(db, t.name, keys, t.pks, batched), values...)
+		}
+		batched = 0
+		values = values[:0]
+		pks = make(map[string]bool)
+	}
+
+	for i, args := range rows {
+		obj := t.build(args, inserting)
+		k := t.columns(obj)
+
+		pk, hasPK := t.pk(obj)
+		// rows with different columns (NewTableFromSchema) can't share an insert
+		if !sameKeys(keys, k) || (batched+1)*len(k) > maxParameters || (hasPK && pks[pk]) {
+			flush()
+			keys = k
+		}
+		if hasPK {
+			pks[pk] = true
+		}
+
+		for _, k := range keys {
+			values = append(values, obj[k])
+		}
+		batched++
+		inserted[i] = typed.Typed(obj)
+	}
+	flush()
+	return inserted
+}
+
+// The row that Insert(args...) would insert, without inserting it (or any
+// missing parent)
+func (t Table) Build(args ...any) typed.Typed {
+	obj := t.build(t.toKV(args), building)
+	t.columns(obj)
+	return typed.Typed(obj)
+}
+
+// n rows built with the same args (see Build). Use KV.Seq or KV.Sequence in
+// the builder to make them differ
+func (t Table) BuildMany(n int, args ...any) []typed.Typed {
+	built := make([]typed.Typed, n)
+	for i := range built {
+		built[i] = t.Build(args...)
+	}
+	return built
+}
+
+// The insert (or upsert, if the table has pks) that Insert(args...) executes
+func (t Table) InsertSQL(args ...any) string {
+	return t.sql(t.columns(t.build(t.toKV(args), peeking)))
+}
+
+// The delete that Truncate executes
+func (t Table) DeleteSQL() string {
+	return t.deleteSQL
+}
+
+func (t Table) sql(keys []string) string {
+	return insertSQL(t.storage(), t.name, keys, t.pks, 1)
+}
+
+func (t Table) storage() SQLStorage {
+	if db := t.db; db != nil {
+		return db
+	}
+	if db := used(); db != nil {
+		return db
+	}
+	return DB
+}
+
+// The storage registered with Use by the innermost running test, nil if
+// there's none. Panics if unrelated tests (i.e. parallel ones) registered
+// storage, since we can't tell which one the caller is.
+func used() SQLStorage {
+	var innermost testing.TB
+	var db SQLStorage
+	scoped.Range(func(key any, value any) bool {
+		tb := key.(testing.TB)
+		switch {
+		case innermost == nil || strings.HasPrefix(tb.Name(), innermost.Name()+"/"):
+			innermost, db = tb, value.(SQLStorage)
+		case strings.HasPrefix(innermost.Name(), tb.Name()+"/"):
+		default:
+			panic("factory: " + innermost.Name() + " and " + tb.Name() + " are using storage in parallel, access tables through Table.For(t)")
+		}
+		return true
+	})
+	return db
+}
+
+// Like ToKV, but expands any leading trait names
+func (t Table) toKV(args []any) KV {
+	kv := KV{}
+	for len(args) > 0 {
+		name, ok := args[0].(string)
+		if !ok {
+			break
+		}
+		trait, ok := t.traits[name]
+		if !ok {
+			break
+		}
+		for k, v := range trait {
+			kv[k] = v
+		}
+		args = args[1:]
+	}
+
+	for k, v := range ToKV(args) {
+		kv[k] = v
+	}
+	return kv
+}
+
+// What build does with a row (and its missing parents)
+type mode int
+
+const (
+	// missing parents are inserted
+	inserting mode = iota
+	// missing parents are only built
+	building
+	// like building, without using up sequence numbers (the row is numbered
+	// as the next one built will be)
+	peeking
+)
+
+// Runs the builder, after numbering the row and resolving associations
+func (t Table) build(args KV, m mode) KV {
+	kv := make(KV, len(args)+1)
+	for k, v := range args {
+		kv[k] = v
+	}
+	if m == peeking {
+		kv[sequenceKey] = int(atomic.LoadInt64(t.sequence) + 1)
+	} else {
+		kv[sequenceKey] = int(atomic.AddInt64(t.sequence, 1))
+	}
+
+	for _, a := range t.associations {
+		value, exists := kv[a.column]
+		switch v := value.(type) {
+		case typed.Typed:
+			kv[a.column] = v[a.key]
+		case KV:
+			kv[a.column] = v[a.key]
+		case map[string]any:
+			kv[a.column] = v[a.key]
+		default:
+			if !exists {
+				var parent KV
+				p := a.parent
+				if t.db != nil {
+					p = p.With(t.db)
+				}
+				if m == inserting {
+					parent = KV(p.Insert())
+				} else {
+					parent = p.build(KV{}, m)
+				}
+				kv[a.column] = parent[a.key]
+			}
+		}
+	}
+
+	// in case the builder copied its args
+	obj := t.builder(kv)
+	delete(obj, sequenceKey)
+	return obj
+}
+
+// The columns to insert for the given row
+func (t Table) columns(obj KV) []string {
+	if t.schema == nil {
+		return t.keys
+	}
+
+	t.unknownColumns(obj)
+
+	keys := make([]string, 0, len(obj))
+	for _, c := range t.schema {
+		value, exists := obj[c.Name]
+		if value == nil {
+			if c.NotNull && !c.Default {
+				panic("factory: table " + t.name + " requires a value for " + c.Name)
+			}
+			if !exists || c.Default {
+				continue
+			}
+		}
+		keys = append(keys, c.Name)
+	}
+	return keys
+}
+
+// The row's primary key, as a string, if the table has pks and the row has
+// a value for each
+func (t Table) pk(obj KV) (string, bool) {
+	if len(t.pks) == 0 {
+		return "", false
+	}
+	parts := make([]string, len(t.pks))
+	for i, k := range t.pks {
+		v := obj[k]
+		if v == nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+func (t Table) unknownColumns(obj KV) {
+	for k := range obj {
+		if !t.hasColumn(k) {
+			panic("factory: table " + t.name + " has no column " + k)
+		}
+	}
+}
+
+func (t Table) hasColumn(name string) bool {
+	if t.schema == nil {
+		i := sort.SearchStrings(t.keys, name)
+		return i < len(t.keys) && t.keys[i] == name
+	}
+	for _, c := range t.schema {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func insertSQL(db SQLStorage, name string, keys []string, pks []string, rows int) string {
+	if len(keys) == 0 {
+		return "insert into " + name + " default values"
+	}
+
+	values := make([]string, rows)
+	placeholders := make([]string, len(keys))
+	for r := 0; r < rows; r++ {
+		for i := range keys {
+			placeholders[i] = db.Placeholder(r*len(keys) + i)
+		}
+		values[r] = "(" + strings.Join(placeholders, ",") + ")"
+	}
+
+	sql := "insert into " + name + " (" + strings.Join(keys, ",") + ")"
+	sql += "\nvalues " + strings.Join(values, ",\n")
+	if len(pks) > 0 {
+		sql += "\non conflict (" + strings.Join(pks, ",") + ") do update set "
+		sql += keys[0] + " = excluded." + keys[0]
+		for _, k := range keys[1:] {
+			sql += ", " + k + " = excluded." + k
+		}
+	}
+	return sql
+}
+
+// version (like "3.39.4") is at least major.minor
+func atLeast(version string, major int, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	actualMajor, _ := strconv.Atoi(parts[0])
+	actualMinor, _ := strconv.Atoi(parts[1])
+	return actualMajor > major || (actualMajor == major && actualMinor >= minor)
+}
+
+func sameKeys(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Where the row's sequence number is stored in the KV given to a builder
+const sequenceKey = "$sequence"
+
 type KV map[string]any
 
 func ToKV(opts []any) KV {
@@ -78,6 +608,22 @@ func ToKV(opts []any) KV {
 	return args
 }
 
+// The row's sequence number within its table: 1 for the first row built,
+// 2 for the second, and so on. 0 when the table is being defined.
+func (kv KV) Seq() int {
+	seq, _ := kv[sequenceKey].(int)
+	return seq
+}
+
+// The given value or format with the row's sequence number, useful for
+// unique columns, e.g. kv.Sequence("email", "user-%d@sqlkite.test")
+func (kv KV) Sequence(key string, format string) any {
+	if value, exists := kv[key]; exists {
+		return value
+	}
+	return fmt.Sprintf(format, kv.Seq())
+}
+
 func (kv KV) UUID(key string, deflt ...string) any {
 	if value, exists := kv[key]; exists {
 		return value.(string)
@@ -0,0 +1,281 @@
+package factory
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/utils/typed"
+)
+
+// A sqlite-like QueryStorage which records what's executed
+type fakeDB struct {
+	statements []statement
+	// what pragma_table_info returns, by table
+	tables map[string][]typed.Typed
+	// the row a "returning *" returns
+	returned typed.Typed
+}
+
+type statement struct {
+	sql  string
+	args []any
+}
+
+func (db *fakeDB) Placeholder(i int) string {
+	return "?" + strconv.Itoa(i+1)
+}
+
+func (db *fakeDB) MustExec(sql string, args ...any) {
+	db.statements = append(db.statements, statement{sql: sql, args: args})
+}
+
+func (db *fakeDB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	switch {
+	case strings.Contains(sql, "pragma_table_info"):
+		return db.tables[args[0].(string)], nil
+	case strings.Contains(sql, "sqlite_version"):
+		return []typed.Typed{{"version": "3.45.1"}}, nil
+	}
+	db.MustExec(sql, args...)
+	return []typed.Typed{db.returned}, nil
+}
+
+// The SQL of every statement executed
+func (db *fakeDB) sql() []string {
+	sql := make([]string, len(db.statements))
+	for i, s := range db.statements {
+		sql[i] = s.sql
+	}
+	return sql
+}
+
+func column(name string, typ string, notNull bool, hasDefault bool, pk int) typed.Typed {
+	return typed.Typed{"name": name, "type": typ, "not_null": boolInt(notNull), "has_default": boolInt(hasDefault), "pk": pk}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Sets DB for the rest of the test
+func setDB(t *testing.T, db SQLStorage) {
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+	})
+}
+
+func users() Table {
+	return NewTable("users", func(args KV) KV {
+		return KV{
+			"name":  args.String("name", "leto"),
+			"id":    args.Int("id", 1),
+			"email": args.String("email", "leto@sqlkite.test"),
+		}
+	}, "id")
+}
+
+func projects() Table {
+	return NewTable("projects", func(args KV) KV {
+		return KV{"id": args.Int("id", 1), "user_id": args["user_id"]}
+	}, "id").BelongsTo("user_id", users(), "id")
+}
+
+func expectPanic(t *testing.T, contains string, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Fatalf("expected a panic containing %q", contains)
+		}
+		if s, _ := r.(string); !strings.Contains(s, contains) {
+			t.Fatalf("expected a panic containing %q, got %v", contains, r)
+		}
+	}()
+	fn()
+}
+
+func TestNewTableFromSchema(t *testing.T) {
+	db := &fakeDB{tables: map[string][]typed.Typed{
+		"users": {
+			column("id", "integer", false, false, 1),
+			column("name", "text", true, false, 0),
+			column("note", "text", false, true, 0),
+			column("bio", "text", false, false, 0),
+		},
+	}}
+	setDB(t, db)
+	// whatever it's given
+	table := NewTableFromSchema("users", func(args KV) KV { return args })
+
+	table.Insert("name", "leto", "bio", nil)
+	expected := "insert into users (name,bio)\nvalues (?1,?2)"
+	if sql := db.sql(); len(sql) != 1 || sql[0] != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%v", expected, sql)
+	}
+
+	expectPanic(t, "requires a value for name", func() { table.Insert() })
+	expectPanic(t, "has no column nope", func() { table.Insert("name", "leto", "nope", 1) })
+	expectPanic(t, "has no column other", func() {
+		NewTableFromSchema("users", func(args KV) KV { return KV{"other": 1} })
+	})
+	expectPanic(t, "does not exist", func() {
+		NewTableFromSchema("nope", func(args KV) KV { return KV{} })
+	})
+}
+
+func TestInsertSQL(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	table := users()
+
+	expected := "insert into users (email,id,name)\nvalues (?1,?2,?3)\non conflict (id) do update set email = excluded.email, id = excluded.id, name = excluded.name"
+	if sql := table.InsertSQL(); sql != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, sql)
+	}
+	if sql := table.DeleteSQL(); sql != "delete from users" {
+		t.Fatalf("unexpected delete: %s", sql)
+	}
+
+	table.Insert("name", "paul")
+	if len(db.statements) != 1 || db.statements[0].sql != expected {
+		t.Fatalf("expected the insert, got %v", db.sql())
+	}
+	if args := db.statements[0].args; !reflect.DeepEqual(args, []any{"leto@sqlkite.test", 1, "paul"}) {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}
+
+func TestInsertAllBatches(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	users().InsertMany(400, func(i int) []any { return []any{"id", i} })
+
+	// 3 columns, so 333 rows fit within 999 parameters
+	if len(db.statements) != 2 || len(db.statements[0].args) != 999 || len(db.statements[1].args) != 201 {
+		t.Fatalf("expected batches of 333 and 67 rows, got %d statements", len(db.statements))
+	}
+
+	db.statements = nil
+	inserted := users().InsertAll([]KV{{"id": 1}, {"id": 2}, {"id": 1, "name": "paul"}})
+	if len(db.statements) != 2 || len(db.statements[0].args) != 6 || len(db.statements[1].args) != 3 {
+		t.Fatalf("expected a repeated pk to start a new batch, got %v", db.sql())
+	}
+	if inserted[2].String("name") != "paul" {
+		t.Fatalf("unexpected rows: %v", inserted)
+	}
+}
+
+func TestReturning(t *testing.T) {
+	db := &fakeDB{returned: typed.Typed{"id": 9, "name": "LETO", "email": "x"}}
+	setDB(t, db)
+	table := NewTable("users", func(args KV) KV {
+		return KV{"id": args["id"], "name": args.String("name", "leto"), "email": args["email"]}
+	}).Returning()
+
+	row := table.Insert("email", "x")
+	expected := "insert into users (email,name)\nvalues (?1,?2)\nreturning *"
+	if sql := db.sql(); len(sql) != 1 || sql[0] != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%v", expected, sql)
+	}
+	if row.Int("id") != 9 || row.String("name") != "LETO" {
+		t.Fatalf("expected the returned values, got %v", row)
+	}
+}
+
+func TestBelongsTo(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	project := projects().Insert("user_id", typed.Typed{"id": 7})
+	if len(db.statements) != 1 || project.Int("user_id") != 7 {
+		t.Fatalf("expected the given parent to be used, got %v", db.sql())
+	}
+
+	db.statements = nil
+	project = projects().Insert()
+	if len(db.statements) != 2 || !strings.HasPrefix(db.statements[0].sql, "insert into users") || project.Int("user_id") != 1 {
+		t.Fatalf("expected a parent to be inserted first, got %v", db.sql())
+	}
+}
+
+func TestTraits(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	table := users().Trait("admin", "name", "ghanima", "email", "admin@sqlkite.test")
+
+	row := table.Insert("admin", "name", "leto")
+	if row.String("name") != "leto" || row.String("email") != "admin@sqlkite.test" {
+		t.Fatalf("expected the trait with explicit args taking precedence, got %v", row)
+	}
+	if _, exists := row[sequenceKey]; exists {
+		t.Fatalf("sequence leaked into the row: %v", row)
+	}
+
+	expectPanic(t, "same name as a column", func() { table.Trait("name") })
+}
+
+func TestSequence(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	table := NewTable("users", func(args KV) KV {
+		// copies args through, $sequence included
+		args["email"] = args.Sequence("email", "user-%d@sqlkite.test")
+		return args
+	})
+
+	table.InsertSQL()
+	first := table.Insert()
+	second := table.Insert()
+	if first.String("email") != "user-1@sqlkite.test" || second.String("email") != "user-2@sqlkite.test" {
+		t.Fatalf("unexpected rows: %v, %v", first, second)
+	}
+	if _, exists := first[sequenceKey]; exists {
+		t.Fatalf("sequence leaked into the row: %v", first)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	db := &fakeDB{}
+	setDB(t, db)
+	built := projects().BuildMany(2)
+	if len(db.statements) != 0 {
+		t.Fatalf("expected nothing to be executed, got %v", db.sql())
+	}
+	if len(built) != 2 || built[1].Int("user_id") != 1 {
+		t.Fatalf("unexpected rows: %v", built)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	global, explicit, scoped := &fakeDB{}, &fakeDB{}, &fakeDB{}
+	setDB(t, global)
+
+	table := NewTable("users", func(args KV) KV { return KV{"id": args["id"]} })
+	table.Insert()
+	table.With(explicit).Insert()
+	if len(global.statements) != 1 || len(explicit.statements) != 1 {
+		t.Fatalf("expected DB and the explicit storage to be used, got %d and %d", len(global.statements), len(explicit.statements))
+	}
+
+	t.Run("scoped", func(t *testing.T) {
+		Use(t, scoped)
+		table.For(t).Insert()
+		table.Insert()
+		table.With(explicit).Insert()
+		if len(scoped.statements) != 2 || len(global.statements) != 1 || len(explicit.statements) != 2 {
+			t.Fatalf("expected the test's storage to be used, got %v", scoped.sql())
+		}
+	})
+
+	table.Insert()
+	if len(global.statements) != 2 {
+		t.Fatal("expected DB to be used once the test ended")
+	}
+}
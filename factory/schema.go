@@ -0,0 +1,97 @@
+package factory
+
+import (
+	"strings"
+)
+
+type Column struct {
+	Name    string
+	NotNull bool
+	PK      bool
+	// Has a default, or is otherwise generated by the database (identity,
+	// serial, sqlite's rowid alias)
+	Default bool
+}
+
+// The columns of table, in declaration order. Returns no columns if the
+// table doesn't exist.
+func Columns(db Querier, table string) ([]Column, error) {
+	if isSQLite(db) {
+		return sqliteColumns(db, table)
+	}
+	return pgColumns(db, table)
+}
+
+func sqliteColumns(db Querier, table string) ([]Column, error) {
+	rows, err := db.RowsToMap(`
+		select name, type, "notnull" as not_null, dflt_value is not null as has_default, pk
+		from pragma_table_info(`+db.Placeholder(0)+`)
+		order by cid
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	pks := 0
+	for _, row := range rows {
+		if row.Int("pk") > 0 {
+			pks++
+		}
+	}
+
+	columns := make([]Column, len(rows))
+	for i, row := range rows {
+		pk := row.Int("pk") > 0
+		columns[i] = Column{
+			Name:    row.String("name"),
+			NotNull: row.Int("not_null") == 1,
+			PK:      pk,
+			// a single "integer primary key" is an alias for the rowid
+			Default: row.Int("has_default") == 1 || (pk && pks == 1 && strings.EqualFold(row.String("type"), "integer")),
+		}
+	}
+	return columns, nil
+}
+
+// postgres and cockroach
+func pgColumns(db Querier, table string) ([]Column, error) {
+	rows, err := db.RowsToMap(`
+		select c.column_name as name,
+			c.is_nullable = 'NO' as not_null,
+			(c.column_default is not null or c.is_identity = 'YES' or c.is_generated = 'ALWAYS') as has_default,
+			exists (
+				select 1
+				from information_schema.table_constraints tc
+					join information_schema.key_column_usage k on k.constraint_name = tc.constraint_name
+						and k.table_schema = tc.table_schema and k.table_name = tc.table_name
+				where tc.table_schema = c.table_schema and tc.table_name = c.table_name
+					and tc.constraint_type = 'PRIMARY KEY' and k.column_name = c.column_name
+			) as pk
+		from information_schema.columns c
+		where c.table_schema = current_schema() and c.table_name = `+db.Placeholder(0)+`
+		order by c.ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]Column, len(rows))
+	for i, row := range rows {
+		columns[i] = Column{
+			Name:    row.String("name"),
+			NotNull: row.Bool("not_null"),
+			PK:      row.Bool("pk"),
+			Default: row.Bool("has_default"),
+		}
+	}
+	return columns, nil
+}
+
+type placeholder interface {
+	Placeholder(i int) string
+}
+
+// Same check as tests.Row, not something anyone's going to like
+func isSQLite(db placeholder) bool {
+	return strings.HasPrefix(db.Placeholder(0), "?")
+}
@@ -1,7 +1,17 @@
 package tests
 
 import (
+	"fmt"
+	"hash/fnv"
+	"math"
 	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/google/uuid"
@@ -9,32 +19,493 @@ import (
 
 var validChars = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789[]{}=_!?<>!@#$%^&*() \t\n\r")
 
-type Generator struct{}
+var (
+	identifierStart = []byte("abcdefghijklmnopqrstuvwxyz")
+	identifierChars = []byte("abcdefghijklmnopqrstuvwxyz0123456789_")
+	urlChars        = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._~")
+)
+
+// Keywords which an Identifier() could randomly generate but which can't
+// be used unquoted as a name in sqlite, postgres or cockroach.
+var reservedIdentifiers = func() map[string]bool {
+	words := strings.Fields(`
+		abort action add after all alter always analyse analyze and any array
+		as asc asymmetric attach authorization autoincrement before begin
+		between binary both by cascade case cast check collate collation
+		column commit concurrently conflict constraint create cross current
+		current_catalog current_date current_role current_schema current_time
+		current_timestamp current_user database default deferrable deferred
+		delete desc detach distinct do drop each else end escape except
+		exclude exclusive exists explain fail false family fetch filter first
+		following for foreign freeze from full generated glob grant group
+		groups having if ignore ilike immediate in index indexed initially
+		inner insert instead intersect into is isnull join key last lateral
+		leading left like limit localtime localtimestamp match materialized
+		natural no not nothing notnull null nulls of off offset on only or
+		order others outer over overlaps partition placing plan pragma
+		preceding primary query raise range recursive references regexp
+		reindex release rename replace restrict returning right rollback row
+		rows savepoint select session_user set similar some symmetric
+		system_user table tablesample temp temporary then ties to trailing
+		transaction trigger true unbounded union unique update use user using
+		vacuum values variadic verbose view virtual when where window with
+		without
+	`)
+	reserved := make(map[string]bool, len(words))
+	for _, word := range words {
+		reserved[word] = true
+	}
+	return reserved
+}()
+
+// A character profile for String(). Values are built by appending atoms,
+// which can be more than one rune (combining sequences) or not even valid
+// UTF-8.
+type profile struct {
+	atoms []string
+	// when no length is given, occasionally generate a very long value
+	long bool
+}
+
+var unicodeAtoms = []string{
+	"a", "Z", "0", " ", "é", "ß", "ñ", "Ω", "ж", "ش", "א", "中", "日", "本", "한",
+	"😀", "🎉", "𝄞", "👩\u200d💻", "🇨🇦", "e\u0301", "a\u0308", "\u200b", "\ufeff",
+}
+
+var hostileAtoms = append([]string{
+	"\x00", "\xff", "\xc3\x28", "\xed\xa0\x80", "'", "\"", "`", "--", ";", "/*", "*/",
+	"\\", "%", "_", "$1", "?1", "?", ":name", "\r\n", "\t", "' or '1'='1", "'); drop table x; --",
+}, unicodeAtoms...)
+
+var (
+	unicodeProfile = &profile{atoms: unicodeAtoms}
+	hostileProfile = &profile{atoms: hostileAtoms, long: true}
+)
+
+// The seed every NewGenerator is derived from. Random per run unless
+// SQLKITE_TEST_SEED is set, which is how a failing run gets replayed.
+var Seed = seed()
+
+// The time Seed was taken (since a random Seed is the time in nanoseconds),
+// which Time() uses as now. Replaying with SQLKITE_TEST_SEED gives the
+// original run's time.
+var seedTime = time.Unix(0, Seed).UTC()
+
+// A zero Generator{} uses the global math/rand source (not reproducible).
+// Use NewGenerator or SeededGenerator to get one with its own source.
+type Generator struct {
+	src     *source
+	profile *profile
+	bytes   bool
+}
+
+// Generator seeded from Seed and the test's name, so a test gets the same
+// values on a replay regardless of which other tests run. On failure, the
+// seed is logged.
+func NewGenerator(t *testing.T) Generator {
+	return SeededGenerator(testSeed(t))
+}
+
+func SeededGenerator(seed int64) Generator {
+	return Generator{src: &source{r: rand.New(rand.NewSource(seed))}}
+}
+
+func (g Generator) UUID() (value string) {
+	defer g.generated("UUID", &value)()
+	if g.src == nil {
+		return uuid.Must(uuid.NewRandom()).String()
+	}
+	return uuid.Must(uuid.NewRandomFromReader(g.src)).String()
+}
+
+// Strings are generated from ASCII letters, digits, punctuation and whitespace (the default)
+func (g Generator) ASCII() Generator {
+	g.profile = nil
+	return g
+}
+
+// Strings are generated from multi-byte UTF-8, combining characters, emoji,
+// RTL and zero-width characters
+func (g Generator) Unicode() Generator {
+	g.profile = unicodeProfile
+	return g
+}
+
+// Strings are generated from everything in Unicode() plus NUL bytes, invalid
+// UTF-8 and SQL metacharacters. Without a length, String() will occasionally
+// generate a very long value.
+func (g Generator) Hostile() Generator {
+	g.profile = hostileProfile
+	return g
+}
+
+// String() lengths are measured in runes (the default). Invalid UTF-8
+// bytes each count as 1 rune
+func (g Generator) InRunes() Generator {
+	g.bytes = false
+	return g
+}
 
-func (_ Generator) UUID() string {
-	return uuid.Must(uuid.NewRandom()).String()
+// String() lengths are measured in bytes
+func (g Generator) InBytes() Generator {
+	g.bytes = true
+	return g
 }
 
 // Generate a random string
 // No arguments: 0-200 length
 // Single integer: exactly N length
 // Two integers: between A and B lengths
-func (g Generator) String(constraints ...int) string {
+func (g Generator) String(constraints ...int) (value string) {
+	defer g.generated("String", &value)()
 	switch len(constraints) {
 	case 0:
-		return g.String(rand.Intn(200))
+		if p := g.profile; p != nil && p.long && g.intn(10) == 0 {
+			return g.String(g.between(10000, 70000))
+		}
+		return g.String(g.intn(200))
 	case 1:
 		l := constraints[0]
+		if g.profile != nil {
+			return g.profiled(l)
+		}
 		str := make([]byte, l)
 		for i := 0; i < l; i++ {
-			str[i] = validChars[rand.Intn(len(validChars))]
+			str[i] = validChars[g.intn(len(validChars))]
 		}
 		return *(*string)(unsafe.Pointer(&str))
+	case 2:
+		return g.String(g.between(constraints[0], constraints[1]))
+	default:
+		panic("String() should take 0 (random), 1 (exact length) or 2 (between A and B length) integers")
+	}
+}
+
+func (g Generator) profiled(l int) string {
+	measure := utf8.RuneCountInString
+	if g.bytes {
+		measure = func(s string) int { return len(s) }
+	}
+
+	sb := strings.Builder{}
+	sb.Grow(l)
+
+	atoms := g.profile.atoms
+	for n := 0; n < l; {
+		atom := atoms[g.intn(len(atoms))]
+		m := measure(atom)
+		if n+m > l {
+			// the atom doesn't fit, pad with something that's 1 rune and 1 byte
+			atom, m = string(identifierStart[g.intn(len(identifierStart))]), 1
+		}
+		sb.WriteString(atom)
+		n += m
+	}
+	return sb.String()
+}
+
+// Generate a random int
+// No arguments: 0 - math.MaxInt32
+// Single integer: between 0 and N
+// Two integers: between A and B
+func (g Generator) Int(constraints ...int) (value int) {
+	defer g.generated("Int", &value)()
+	switch len(constraints) {
+	case 0:
+		return g.intn(math.MaxInt32)
+	case 1:
+		return g.intn(constraints[0] + 1)
+	case 2:
+		return g.between(constraints[0], constraints[1])
+	default:
+		panic("Int() should take 0 (random), 1 (between 0 and N) or 2 (between A and B) integers")
+	}
+}
+
+// Generate a random float
+// No arguments: [0, 1)
+// Single float: [0, N)
+// Two floats: [A, B)
+func (g Generator) Float(constraints ...float64) (value float64) {
+	defer g.generated("Float", &value)()
+	switch len(constraints) {
+	case 0:
+		return g.float64()
+	case 1:
+		return g.float64() * constraints[0]
 	case 2:
 		min := constraints[0]
 		max := constraints[1]
-		return g.String(rand.Intn(max-min+1) + min)
+		return min + g.float64()*(max-min)
 	default:
-		panic("String() should take 0 (random), 1 (exact length) or 2 (between A and B length) integers")
+		panic("Float() should take 0 (random), 1 (between 0 and N) or 2 (between A and B) floats")
+	}
+}
+
+func (g Generator) Bool() (value bool) {
+	defer g.generated("Bool", &value)()
+	return g.intn(2) == 1
+}
+
+// Generate random bytes
+// No arguments: 0-200 length
+// Single integer: exactly N length
+// Two integers: between A and B lengths
+func (g Generator) Bytes(constraints ...int) (value []byte) {
+	defer g.generated("Bytes", &value)()
+	b := make([]byte, g.length("Bytes", 0, 200, constraints))
+	for i := range b {
+		b[i] = byte(g.intn(256))
+	}
+	return b
+}
+
+// Generate a lowercase identifier which can be used, unquoted, as a
+// table or column name on every backend.
+// No arguments: 1-63 length (63 being postgres' limit)
+// Single integer: exactly N length
+// Two integers: between A and B lengths
+func (g Generator) Identifier(constraints ...int) (value string) {
+	defer g.generated("Identifier", &value)()
+	l := g.length("Identifier", 1, 63, constraints)
+	if l == 0 {
+		panic("Identifier() length must be at least 1")
+	}
+	for {
+		id := make([]byte, l)
+		id[0] = identifierStart[g.intn(len(identifierStart))]
+		for i := 1; i < l; i++ {
+			id[i] = identifierChars[g.intn(len(identifierChars))]
+		}
+		if s := string(id); !reservedIdentifiers[s] {
+			return s
+		}
+	}
+}
+
+// Generate a random email. The constraints apply to the local part (before the @)
+// No arguments: 1-30 length
+// Single integer: exactly N length
+// Two integers: between A and B lengths
+func (g Generator) Email(constraints ...int) (value string) {
+	defer g.generated("Email", &value)()
+	l := g.length("Email", 1, 30, constraints)
+	return g.Identifier(l) + "@" + g.Identifier(3, 12) + ".test"
+}
+
+// Generate a random https URL. The constraints apply to the path
+// No arguments: 0-100 length
+// Single integer: exactly N length
+// Two integers: between A and B lengths
+func (g Generator) URL(constraints ...int) (value string) {
+	defer g.generated("URL", &value)()
+	l := g.length("URL", 0, 100, constraints)
+	path := make([]byte, l)
+	for i := range path {
+		path[i] = urlChars[g.intn(len(urlChars))]
+	}
+	return "https://" + g.Identifier(3, 12) + ".test/" + string(path)
+}
+
+// Generate a random UTC time, truncated to the microsecond since that's
+// what postgres and cockroach store.
+// "now" is seedTime rather than the actual time, so that a replay generates
+// the same times.
+// No arguments: within a year (before or after) of now
+// Single time: between now and T
+// Two times: between A and B
+func (g Generator) Time(constraints ...time.Time) (value time.Time) {
+	defer g.generated("Time", &value)()
+	var from, to time.Time
+	switch len(constraints) {
+	case 0:
+		from, to = seedTime.AddDate(-1, 0, 0), seedTime.AddDate(1, 0, 0)
+	case 1:
+		from, to = seedTime, constraints[0]
+	case 2:
+		from, to = constraints[0], constraints[1]
+	default:
+		panic("Time() should take 0 (random), 1 (between now and T) or 2 (between A and B) times")
+	}
+	if to.Before(from) {
+		from, to = to, from
+	}
+	offset := time.Duration(g.float64() * float64(to.Sub(from)))
+	return from.Add(offset).UTC().Truncate(time.Microsecond)
+}
+
+// Generate a random JSON object (which can be passed to json.Marshal)
+// No arguments: 0-3 levels of nesting
+// Single integer: exactly N levels of nesting
+// Two integers: between A and B levels of nesting
+func (g Generator) JSON(constraints ...int) (value map[string]any) {
+	defer g.generated("JSON", &value)()
+	return g.jsonObject(g.length("JSON", 0, 3, constraints))
+}
+
+func (g Generator) jsonObject(depth int) map[string]any {
+	// the first key always holds the nested value, so that we
+	// get exactly the requested depth
+	l := g.between(1, 5)
+	obj := make(map[string]any, l)
+	for i := 0; i < l; i++ {
+		key := g.Identifier(1, 20)
+		if i == 0 && depth > 0 {
+			if g.Bool() {
+				obj[key] = g.jsonObject(depth - 1)
+			} else {
+				obj[key] = []any{g.jsonObject(depth - 1)}
+			}
+		} else if _, exists := obj[key]; !exists {
+			obj[key] = g.jsonScalar()
+		}
+	}
+	return obj
+}
+
+func (g Generator) jsonScalar() any {
+	switch g.intn(6) {
+	case 0:
+		return nil
+	case 1:
+		return g.Bool()
+	case 2:
+		return g.Int(-1000000, 1000000)
+	case 3:
+		return g.Float(-1000, 1000)
+	case 4:
+		return []any{g.String(0, 20), g.Int(), g.Bool()}
+	default:
+		return g.String(0, 50)
+	}
+}
+
+// The 0/1/2 argument constraint that most generators share
+func (g Generator) length(name string, min int, max int, constraints []int) int {
+	switch len(constraints) {
+	case 0:
+		return g.between(min, max)
+	case 1:
+		return constraints[0]
+	case 2:
+		return g.between(constraints[0], constraints[1])
+	default:
+		panic(fmt.Sprintf("%s() should take 0 (random), 1 (exact length) or 2 (between A and B length) integers", name))
+	}
+}
+
+func (g Generator) between(min int, max int) int {
+	return g.intn(max-min+1) + min
+}
+
+// Every random value is built from intn/float64, which lets Check
+// replay and shrink whatever a property generated.
+func (g Generator) float64() float64 {
+	return float64(g.int63n(1<<53)) / (1 << 53)
+}
+
+func (g Generator) intn(n int) int {
+	return int(g.int63n(int64(n)))
+}
+
+func (g Generator) int63n(n int64) int64 {
+	if g.src == nil {
+		return rand.Int63n(n)
+	}
+	return g.src.int63n(n)
+}
+
+type source struct {
+	r *rand.Rand
+
+	// when recording, every draw is kept so that it can be replayed
+	record bool
+	draws  []int64
+
+	// when replaying, draws come from here rather than r, and are 0
+	// once it's exhausted
+	replaying bool
+	replay    []int64
+	position  int
+
+	// the values handed out by the public methods (but not the ones they
+	// use internally, e.g. the Identifier within an Email), for reporting
+	// a counterexample
+	depth  int
+	values []generated
+}
+
+type generated struct {
+	name  string
+	value any
+}
+
+// Deferred by every public method with a pointer to its result:
+//
+//	defer g.generated("String", &value)()
+func (g Generator) generated(name string, value any) func() {
+	s := g.src
+	if s == nil {
+		return func() {}
+	}
+	s.depth++
+	return func() {
+		s.depth--
+		if s.depth == 0 {
+			s.values = append(s.values, generated{name, reflect.ValueOf(value).Elem().Interface()})
+		}
+	}
+}
+
+func (s *source) int63n(n int64) int64 {
+	var v int64
+	if s.replaying {
+		if i := s.position; i < len(s.replay) {
+			v = s.replay[i]
+			if v >= n {
+				v = n - 1
+			}
+		}
+		s.position++
+	} else {
+		v = s.r.Int63n(n)
+	}
+
+	if s.record {
+		s.draws = append(s.draws, v)
+	}
+	return v
+}
+
+// io.Reader, for uuid.NewRandomFromReader
+func (s *source) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(s.int63n(256))
+	}
+	return len(p), nil
+}
+
+// Seed for the given test, logged if the test fails
+func testSeed(t *testing.T) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(t.Name()))
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("replay with SQLKITE_TEST_SEED=%d", Seed)
+		}
+	})
+	return Seed ^ int64(h.Sum64())
+}
+
+func seed() int64 {
+	env := os.Getenv("SQLKITE_TEST_SEED")
+	if env == "" {
+		return time.Now().UnixNano()
+	}
+	seed, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		panic("Invalid SQLKITE_TEST_SEED value. Should be an integer")
 	}
+	return seed
 }
@@ -0,0 +1,122 @@
+// Compares values the way a test reading from sqlite/postgres/cockroach
+// means them: 1 == int64(1) == float64(1), []byte("a") == "a", and so on.
+package compare
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"time"
+)
+
+func Loose(actual any, expected any) bool {
+	if actual == nil || expected == nil {
+		return isNil(actual) && isNil(expected)
+	}
+
+	if equal, ok := Numbers(reflect.ValueOf(boolToInt(actual)), reflect.ValueOf(boolToInt(expected))); ok {
+		return equal
+	}
+
+	if a, ok := text(actual); ok {
+		if e, ok := text(expected); ok {
+			return bytes.Equal(a, e)
+		}
+	}
+
+	if a, ok := actual.(time.Time); ok {
+		if e, ok := expected.(time.Time); ok {
+			return a.Equal(e)
+		}
+	}
+
+	return reflect.DeepEqual(actual, expected)
+}
+
+// sqlite has no boolean
+func boolToInt(value any) any {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return value
+}
+
+// Compares a and b by value if they're both numbers (ok is false if either
+// isn't). Integers are compared exactly, since a float64 can't hold every
+// int64 (like cockroach's unique_rowid() ids). An integer only equals a
+// float that's exactly that integer.
+func Numbers(a reflect.Value, b reflect.Value) (equal bool, ok bool) {
+	na, ok := number(a)
+	if !ok {
+		return false, false
+	}
+	nb, ok := number(b)
+	if !ok {
+		return false, false
+	}
+
+	if na.float || nb.float {
+		if na.float && nb.float {
+			return na.f == nb.f, true
+		}
+		if na.float {
+			na, nb = nb, na
+		}
+		// na is the integer, nb the float
+		f := nb.f
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxUint64 {
+			return false, true
+		}
+		if f >= 0 {
+			return !na.negative && na.u == uint64(f), true
+		}
+		return na.negative && int64(na.u) == int64(f), true
+	}
+	return na.negative == nb.negative && na.u == nb.u, true
+}
+
+type num struct {
+	float    bool
+	f        float64
+	negative bool
+	// the integer's bits (as an int64 when negative)
+	u uint64
+}
+
+func number(v reflect.Value) (num, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		return num{negative: n < 0, u: uint64(n)}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return num{u: v.Uint()}, true
+	case reflect.Float32, reflect.Float64:
+		return num{float: true, f: v.Float()}, true
+	}
+	return num{}, false
+}
+
+func text(value any) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
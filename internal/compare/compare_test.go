@@ -0,0 +1,48 @@
+package compare
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLoose(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		actual   any
+		expected any
+		equal    bool
+	}{
+		{nil, nil, true},
+		{nil, 0, false},
+		{[]byte(nil), nil, true},
+		{1, int64(1), true},
+		{uint8(1), 1.0, true},
+		{int64(812345678901234567), int64(812345678901234567), true},
+		{int64(812345678901234567), int64(812345678901234560), false},
+		{int64(812345678901234567), float64(812345678901234567), false},
+		{uint64(812345678901234567), int64(812345678901234567), true},
+		{uint64(math.MaxUint64), int64(-1), false},
+		{int64(math.MinInt64), float64(math.MinInt64), true},
+		{int64(-3), -3.0, true},
+		{int64(3), 3.5, false},
+		{1.5, 1.5, true},
+		{true, 1, true},
+		{false, int64(0), true},
+		{true, 0, false},
+		{"a", []byte("a"), true},
+		{"a", "b", false},
+		{"1", 1, false},
+		{now, now.UTC(), true},
+		{[]int{1}, []int{1}, true},
+	}
+
+	for _, tc := range cases {
+		if actual := Loose(tc.actual, tc.expected); actual != tc.equal {
+			t.Errorf("Loose(%#v, %#v): expected %v", tc.actual, tc.expected, tc.equal)
+		}
+		if actual := Loose(tc.expected, tc.actual); actual != tc.equal {
+			t.Errorf("Loose(%#v, %#v): expected %v", tc.expected, tc.actual, tc.equal)
+		}
+	}
+}
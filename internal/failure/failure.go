@@ -0,0 +1,97 @@
+// Every failure raised by assert (and the other helpers) goes through here.
+// Normally that just means t.Errorf + t.FailNow, but some helpers (like
+// tests.Check) need to observe failures rather than have them end the test.
+package failure
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// The part of *testing.T that we need
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	FailNow()
+}
+
+type handler func(message string)
+
+var (
+	lock     sync.Mutex
+	handlers = make(map[TB][]handler)
+)
+
+// Report a failure. Goes to the most recently pushed handler for t, if
+// there is one, otherwise fails t.
+func Report(t TB, message string) {
+	t.Helper()
+	if h := current(t); h != nil {
+		h(message)
+		return
+	}
+	t.Errorf("%s", message)
+	t.FailNow()
+}
+
+// Route failures reported for t to h until the returned function is called.
+// Handlers are looked up by t, so t has to be comparable (like a pointer).
+func Push(t TB, h func(message string)) func() {
+	if !reflect.TypeOf(t).Comparable() {
+		panic(fmt.Sprintf("%T can't be used here, since it isn't comparable (use a pointer to it)", t))
+	}
+	lock.Lock()
+	handlers[t] = append(handlers[t], h)
+	lock.Unlock()
+
+	return func() {
+		lock.Lock()
+		defer lock.Unlock()
+		hs := handlers[t]
+		if len(hs) == 1 {
+			delete(handlers, t)
+		} else {
+			handlers[t] = hs[:len(hs)-1]
+		}
+	}
+}
+
+type captured struct {
+	message string
+}
+
+// Runs fn and returns the first failure reported for t (if any). Like a
+// normal failure, this stops fn, but not the test.
+func Capture(t TB, fn func()) (message string, failed bool) {
+	defer Push(t, func(message string) {
+		panic(captured{message})
+	})()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(captured)
+			if !ok {
+				panic(r)
+			}
+			message, failed = c.message, true
+		}
+	}()
+
+	fn()
+	return "", false
+}
+
+func current(t TB) handler {
+	// nothing could have been pushed for it
+	if !reflect.TypeOf(t).Comparable() {
+		return nil
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	hs := handlers[t]
+	if len(hs) == 0 {
+		return nil
+	}
+	return hs[len(hs)-1]
+}
@@ -0,0 +1,55 @@
+package failure
+
+import (
+	"fmt"
+	"testing"
+)
+
+// A harness's TB which, being a struct with a slice, isn't comparable
+type recorder struct {
+	messages *[]string
+	extra    []string
+}
+
+func (r recorder) Helper()  {}
+func (r recorder) FailNow() {}
+func (r recorder) Errorf(format string, args ...any) {
+	*r.messages = append(*r.messages, fmt.Sprintf(format, args...))
+}
+
+func TestReportUncomparable(t *testing.T) {
+	var messages []string
+	Report(recorder{messages: &messages}, "oops")
+	if len(messages) != 1 || messages[0] != "oops" {
+		t.Fatalf("expected the failure to be reported, got %v", messages)
+	}
+}
+
+func TestPushUncomparable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	var messages []string
+	Push(recorder{messages: &messages}, func(string) {})
+}
+
+func TestPushAndCapture(t *testing.T) {
+	var seen []string
+	pop := Push(t, func(message string) { seen = append(seen, message) })
+
+	message, failed := Capture(t, func() {
+		Report(t, "inner")
+		t.Fatal("not reached")
+	})
+	if !failed || message != "inner" {
+		t.Fatalf("expected the inner failure to be captured, got %q %v", message, failed)
+	}
+
+	Report(t, "outer")
+	pop()
+	if len(seen) != 1 || seen[0] != "outer" {
+		t.Fatalf("expected the outer handler to see only its failure, got %v", seen)
+	}
+}
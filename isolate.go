@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"src.sqlkite.com/tests/factory"
+)
+
+// A TestableDB which can also execute statements (which makes it
+// a factory.SQLStorage too)
+type IsolatableDB interface {
+	TestableDB
+	MustExec(sql string, args ...any)
+}
+
+// A transaction, which must run everything through its single connection
+type Tx interface {
+	IsolatableDB
+	Rollback() error
+}
+
+var (
+	savepoints int64
+
+	// the tests with an open savepoint, per db
+	savepointsLock sync.Mutex
+	savepointTests = make(map[IsolatableDB][]*testing.T)
+)
+
+// Runs the test in a savepoint on db, which is rolled back when the test
+// ends. This is for sqlite, where there's a single connection; storage with
+// a connection pool (postgres, cockroach) must use IsolateTx, and Isolate
+// fails the test for anything which doesn't use ? placeholders.
+// db is registered (factory.Use) for the test, so factory tables use it.
+// Nothing global (like factory.DB) is changed. Savepoints on one connection
+// nest: rolling one back discards every savepoint opened after it. So tests
+// using the same db can't be parallel (subtests can still Isolate within
+// their parent's savepoint), and Isolate fails the test if they are.
+func Isolate(t *testing.T, db IsolatableDB) IsolatableDB {
+	t.Helper()
+	if !strings.HasPrefix(db.Placeholder(0), "?") {
+		t.Fatalf("tests.Isolate: savepoints are only supported on sqlite, use tests.IsolateTx for %T", db)
+	}
+
+	claimSavepoint(t, db)
+	name := "sqlkite_test_" + strconv.FormatInt(atomic.AddInt64(&savepoints, 1), 10)
+	db.MustExec("savepoint " + name)
+
+	factory.Use(t, db)
+	t.Cleanup(func() {
+		db.MustExec("rollback to savepoint " + name)
+		db.MustExec("release savepoint " + name)
+		releaseSavepoint(t, db)
+	})
+	return db
+}
+
+// Runs the test in the transaction returned by begin, which is rolled back
+// when the test ends. Each test has its own transaction (on its own
+// connection), so tests can be parallel. The transaction is registered
+// (factory.Use) for the test and returned, for tests.Row/Rows. begin
+// adapts the storage's own transaction type:
+//
+//	tx := tests.IsolateTx(t, func() (tests.Tx, error) {
+//		return db.Begin()
+//	})
+func IsolateTx(t *testing.T, begin func() (Tx, error)) IsolatableDB {
+	t.Helper()
+	tx, err := begin()
+	if err != nil {
+		t.Fatalf("tests.IsolateTx: %v", err)
+	}
+
+	factory.Use(t, tx)
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil {
+			panic(err)
+		}
+	})
+	return tx
+}
+
+// Every other test with an open savepoint on db has to be an ancestor of t
+// (a subtest runs within its parent's savepoint), else they're running in
+// parallel.
+func claimSavepoint(t *testing.T, db IsolatableDB) {
+	t.Helper()
+	savepointsLock.Lock()
+	defer savepointsLock.Unlock()
+	for _, other := range savepointTests[db] {
+		if other != t && !strings.HasPrefix(t.Name(), other.Name()+"/") {
+			t.Fatalf("tests.Isolate: %s and %s are using savepoints on the same connection in parallel, which requires tests.IsolateTx", t.Name(), other.Name())
+		}
+	}
+	savepointTests[db] = append(savepointTests[db], t)
+}
+
+func releaseSavepoint(t *testing.T, db IsolatableDB) {
+	savepointsLock.Lock()
+	defer savepointsLock.Unlock()
+	open := savepointTests[db]
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] == t {
+			open = append(open[:i], open[i+1:]...)
+			break
+		}
+	}
+	if len(open) == 0 {
+		delete(savepointTests, db)
+	} else {
+		savepointTests[db] = open
+	}
+}
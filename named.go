@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"reflect"
+
+	"src.sqlkite.com/utils/typed"
+)
+
+// Like Row, but with :name or @name parameters
+func RowNamed(db TestableDB, sql string, params map[string]any) typed.Typed {
+	sql, args := named(db, sql, params)
+	return Row(db, sql, args...)
+}
+
+// Like Rows, but with :name or @name parameters
+func RowsNamed(db TestableDB, sql string, params map[string]any) []typed.Typed {
+	sql, args := named(db, sql, params)
+	return Rows(db, sql, args...)
+}
+
+// Like RowNamed, but the parameters are the fields of a struct (or pointer
+// to a struct), named by their `db` tag (or the field's name if untagged)
+func RowStruct(db TestableDB, sql string, params any) typed.Typed {
+	return RowNamed(db, sql, structParams(params))
+}
+
+// Like RowsNamed, but the parameters are the fields of a struct (or pointer
+// to a struct), named by their `db` tag (or the field's name if untagged)
+func RowsStruct(db TestableDB, sql string, params any) []typed.Typed {
+	return RowsNamed(db, sql, structParams(params))
+}
+
+// Rewrites the named parameters in sql to db's placeholders and returns
+// the matching args. Row/Rows will then leave those placeholders as-is.
+// Using named parameters is opting in to :name and @name being placeholders
+// (even where they could be postgres syntax), but ? still only is one if
+// db uses it.
+func named(db TestableDB, sql string, params map[string]any) (string, []any) {
+	found := scan(sql, syntax{question: usesQuestion(db), colon: true, at: true})
+	if len(found) == 0 {
+		return sql, nil
+	}
+
+	var args []any
+	indexes := make([]int, len(found))
+	lookup := make(map[string]int)
+	for i, p := range found {
+		if p.name == "" {
+			panic("named parameters can't be mixed with positional ones: " + sql[p.start:p.end])
+		}
+		index, exists := lookup[p.name]
+		if !exists {
+			value, ok := params[p.name]
+			if !ok {
+				panic("no value given for parameter " + sql[p.start:p.end])
+			}
+			index = len(args)
+			lookup[p.name] = index
+			args = append(args, value)
+		}
+		indexes[i] = index
+	}
+	return rewrite(db, sql, found, indexes, args)
+}
+
+func structParams(params any) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(params))
+	if v.Kind() != reflect.Struct {
+		panic("expected a struct, got " + v.Kind().String())
+	}
+
+	t := v.Type()
+	m := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := columnName(t.Field(i)); ok {
+			m[name] = v.Field(i).Interface()
+		}
+	}
+	return m
+}
+
+// The column a struct field maps to: its `db` tag or, if untagged, its
+// name. Unexported fields and `db:"-"` are skipped.
+func columnName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("db")
+	if tag == "-" {
+		return "", false
+	}
+	if tag == "" {
+		return f.Name, true
+	}
+	return tag, true
+}
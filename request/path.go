@@ -0,0 +1,121 @@
+package request
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"src.sqlkite.com/utils/typed"
+)
+
+// Finds the value at path, gjson style:
+//
+//	data.user.name   nested keys
+//	items.0.id       an array index
+//	items.#          the length of an array
+//	items.#.id       the id of each item (items without one are skipped)
+//
+// A literal . in a key is escaped as \. and an empty path is the whole
+// value. When the path doesn't exist, returns the part of it that does.
+func lookup(value any, path string) (any, string, bool) {
+	segments := splitPath(path)
+	for i, segment := range segments {
+		value = plain(value)
+		switch v := value.(type) {
+		case map[string]any:
+			child, exists := v[segment]
+			if !exists {
+				return nil, joinPath(segments[:i]), false
+			}
+			value = child
+		case []any:
+			if segment == "#" {
+				rest := segments[i+1:]
+				if len(rest) == 0 {
+					return len(v), path, true
+				}
+				values := make([]any, 0, len(v))
+				for _, item := range v {
+					if child, _, ok := lookup(item, joinPath(rest)); ok {
+						values = append(values, child)
+					}
+				}
+				return values, path, true
+			}
+			n, err := strconv.Atoi(segment)
+			if err != nil || n < 0 || n >= len(v) {
+				return nil, joinPath(segments[:i]), false
+			}
+			value = v[n]
+		default:
+			return nil, joinPath(segments[:i]), false
+		}
+	}
+	return plain(value), path, true
+}
+
+// typed.Typed (and []typed.Typed, []map[string]any) as what they are in
+// JSON, the same as what normalize gives
+func plain(value any) any {
+	switch v := value.(type) {
+	case typed.Typed:
+		return map[string]any(v)
+	case []typed.Typed:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = map[string]any(item)
+		}
+		return items
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	}
+	return value
+}
+
+// value as if it had been decoded from JSON: numbers are float64, structs
+// are maps, and so on
+func normalize(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		panic(err)
+	}
+	return normalized
+}
+
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var segments []string
+	segment := strings.Builder{}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '\\' && i+1 < len(path) && path[i+1] == '.' {
+			segment.WriteByte('.')
+			i++
+		} else if c == '.' {
+			segments = append(segments, segment.String())
+			segment.Reset()
+		} else {
+			segment.WriteByte(c)
+		}
+	}
+	return append(segments, segment.String())
+}
+
+func joinPath(segments []string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = strings.ReplaceAll(segment, ".", `\.`)
+	}
+	return strings.Join(escaped, ".")
+}
@@ -0,0 +1,48 @@
+package request
+
+import (
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/tests/internal/failure"
+	"src.sqlkite.com/utils/typed"
+)
+
+const body = `{"data":{"user":{"name":"leto","a.b":1}},"items":[{"id":1},{"id":2,"tags":["x"]},{}]}`
+
+func TestExpectJSON(t *testing.T) {
+	r := response{t: t, Body: body, Json: typed.Must([]byte(body))}
+	r.ExpectJSON("data.user.name", "leto").
+		ExpectJSON("items.#", 3).
+		ExpectJSON("items.#.id", []int{1, 2}).
+		ExpectJSON("items.1.tags.0", "x").
+		ExpectJSON(`data.user.a\.b`, 1).
+		ExpectJSON("data.user", map[string]any{"name": "leto", "a.b": 1}).
+		ExpectJSON("data", typed.Typed{"user": typed.Typed{"name": "leto", "a.b": 1}}).
+		ExpectJSON("", typed.Must([]byte(body)))
+}
+
+func TestExpectJSONFailures(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	cases := []struct {
+		path     string
+		expected any
+		message  string
+	}{
+		{path: "items.5.id", expected: 1, message: "path not found: items.5.id (only items exists)"},
+		{path: "data.user.nme", expected: 1, message: "path not found: data.user.nme (only data.user exists)"},
+		{path: "nope", expected: 1, message: "path not found: nope\n"},
+		{path: "items.#.id", expected: []int{1, 3}, message: "\nitems.#.id[1]: 2 != 3\n"},
+		{path: "data.user.name", expected: "paul", message: "\ndata.user.name: \"leto\" != \"paul\"\n"},
+	}
+
+	r := response{t: t, Body: body, Json: typed.Must([]byte(body))}
+	for _, tc := range cases {
+		message, failed := failure.Capture(t, func() { r.ExpectJSON(tc.path, tc.expected) })
+		if !failed {
+			t.Errorf("%s: expected a failure", tc.path)
+		} else if !strings.Contains(message, tc.message) {
+			t.Errorf("%s: expected %q in:\n%s", tc.path, tc.message, message)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
-	"testing"
 
 	"src.sqlkite.com/tests/assert"
 	"src.sqlkite.com/utils/http"
@@ -16,7 +15,7 @@ import (
 
 type Handler func(*fasthttp.RequestCtx)
 
-func Req(t *testing.T) RequestBuilder {
+func Req(t assert.TB) RequestBuilder {
 	return RequestBuilder{
 		t:          t,
 		path:       "/",
@@ -28,14 +27,14 @@ func Req(t *testing.T) RequestBuilder {
 
 // Most cases should generates a response from a Req, but some cases will want
 // to test an http.Response directly
-func Response(t *testing.T, res http.Response) response {
+func Response(t assert.TB, res http.Response) response {
 	conn := &fasthttp.RequestCtx{}
 	res.Write(conn)
 	return Res(t, conn)
 }
 
 type RequestBuilder struct {
-	t          *testing.T
+	t          assert.TB
 	host       string
 	body       string
 	path       string
@@ -151,7 +150,7 @@ func (r RequestBuilder) Conn() *fasthttp.RequestCtx {
 	return ctx
 }
 
-func Res(t *testing.T, conn *fasthttp.RequestCtx) response {
+func Res(t assert.TB, conn *fasthttp.RequestCtx) response {
 	res := conn.Response
 
 	body := res.Body()
@@ -189,7 +188,7 @@ func Res(t *testing.T, conn *fasthttp.RequestCtx) response {
 }
 
 type response struct {
-	t             *testing.T
+	t             assert.TB
 	Err           error
 	Status        int
 	Body          string
@@ -246,7 +245,7 @@ func (r response) ExpectValidation(expected ...any) response {
 	assert.Equal(r.t, r.Status, 400)
 	r.ExpectCode(2004)
 
-	valid := true
+	var errors []string
 	lookup := r.Validations
 	for i := 0; i < len(expected); i += 2 {
 		found := false
@@ -259,18 +258,16 @@ func (r response) ExpectValidation(expected ...any) response {
 			if expectedCode == actualCode {
 				break
 			}
-			r.t.Errorf("Expect validation code for field '%s' to be %d, got %d", field, expectedCode, actualCode)
-			valid = false
+			errors = append(errors, fmt.Sprintf("Expect validation code for field '%s' to be %d, got %d", field, expectedCode, actualCode))
 		}
 
 		if !found {
-			r.t.Errorf("No validation error for field '%s'", field)
-			valid = false
+			errors = append(errors, fmt.Sprintf("No validation error for field '%s'", field))
 		}
 	}
 
-	if !valid {
-		r.t.FailNow()
+	if len(errors) > 0 {
+		assert.Fail(r.t, "%s", strings.Join(errors, "\n"))
 	}
 
 	return r
@@ -278,15 +275,14 @@ func (r response) ExpectValidation(expected ...any) response {
 
 func (r response) ExpectNoValidation(fields ...string) response {
 	r.t.Helper()
-	valid := true
+	var errors []string
 	for _, field := range fields {
 		if invalid, exists := r.Validations[field]; exists {
-			valid = false
-			r.t.Errorf("Did not expect an error for field: '%s', got: '%v'", field, invalid)
+			errors = append(errors, fmt.Sprintf("Did not expect an error for field: '%s', got: '%v'", field, invalid))
 		}
 	}
-	if !valid {
-		r.t.FailNow()
+	if len(errors) > 0 {
+		assert.Fail(r.t, "%s", strings.Join(errors, "\n"))
 	}
 	return r
 }
@@ -294,8 +290,7 @@ func (r response) ExpectNoValidation(fields ...string) response {
 func (r response) OK() response {
 	r.t.Helper()
 	if r.Status != 200 && r.Status != 201 && r.Status != 204 {
-		r.t.Errorf("Expect 200/201/204 status code, got: %d\n%s\n%v", r.Status, r.Body, r.Err)
-		r.t.FailNow()
+		assert.Fail(r.t, "Expect 200/201/204 status code, got: %d\n%s\n%v", r.Status, r.Body, r.Err)
 	}
 	return r
 }
@@ -305,6 +300,25 @@ func (r response) JSON() typed.Typed {
 	return typed.Must([]byte(r.Body))
 }
 
+// The value at path in the JSON body equals expected. See lookup for the
+// path syntax, e.g. ExpectJSON("data.user.name", "leto") or
+// ExpectJSON("items.#.id", []int{1, 2}). expected is compared as JSON, so
+// numbers compare by value and a struct matches the object it'd encode to.
+func (r response) ExpectJSON(path string, expected any) response {
+	r.t.Helper()
+	actual, found, ok := lookup(r.Json, path)
+	if !ok {
+		if found != "" {
+			found = " (only " + found + " exists)"
+		}
+		assert.Fail(r.t, "path not found: %s%s\n%s", path, found, r.Body)
+		return r
+	}
+
+	assert.Deep(r.t, actual, normalize(expected), assert.AtPath(path))
+	return r
+}
+
 func (r response) Header(name string, expected string) response {
 	r.t.Helper()
 	name = strings.Title(name)
@@ -15,13 +15,12 @@ package request
 // need to have unique names for each.
 
 import (
-	"testing"
-
 	"github.com/valyala/fasthttp"
+	"src.sqlkite.com/tests/assert"
 	"src.sqlkite.com/utils/http"
 )
 
-func ReqT[T any](t *testing.T, env T) RequestBuilderT[T] {
+func ReqT[T any](t assert.TB, env T) RequestBuilderT[T] {
 	return RequestBuilderT[T]{env, Req(t)}
 }
 
@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"src.sqlkite.com/tests/factory"
+	"src.sqlkite.com/tests/internal/compare"
+	"src.sqlkite.com/tests/internal/failure"
+	"src.sqlkite.com/utils/typed"
+)
+
+type TableSnapshot struct {
+	db     TestableDB
+	tables []*tableRows
+}
+
+type tableRows struct {
+	name string
+	pks  []string
+	// rows by primary key
+	rows map[string]typed.Typed
+	// the primary keys in the order the rows were read
+	keys []string
+}
+
+// Captures the content of tables, so that Diff() can later report what
+// rows were inserted, deleted or updated. Rows are matched by primary key
+// (or, for a table without one, by all of their values, so an update is
+// a delete plus an insert, and identical rows are counted).
+func Snapshot(db TestableDB, tables ...string) *TableSnapshot {
+	s := &TableSnapshot{db: db, tables: make([]*tableRows, len(tables))}
+	for i, name := range tables {
+		columns, err := factory.Columns(db, name)
+		if err != nil {
+			panic(err)
+		}
+		var pks []string
+		for _, c := range columns {
+			if c.PK {
+				pks = append(pks, c.Name)
+			}
+		}
+		s.tables[i] = read(db, name, pks)
+	}
+	return s
+}
+
+// What changed since the snapshot was taken
+func (s *TableSnapshot) Diff() Diff {
+	d := Diff{
+		Inserted: make(map[string][]typed.Typed),
+		Deleted:  make(map[string][]typed.Typed),
+		Updated:  make(map[string][]Change),
+	}
+
+	for _, before := range s.tables {
+		after := read(s.db, before.name, before.pks)
+		name := before.name
+
+		for _, key := range before.keys {
+			old := before.rows[key]
+			row, exists := after.rows[key]
+			if !exists {
+				d.Deleted[name] = append(d.Deleted[name], old)
+				continue
+			}
+			if columns := changed(old, row); len(columns) > 0 {
+				d.Updated[name] = append(d.Updated[name], Change{Before: old, After: row, Columns: columns})
+			}
+		}
+
+		for _, key := range after.keys {
+			if _, exists := before.rows[key]; !exists {
+				d.Inserted[name] = append(d.Inserted[name], after.rows[key])
+			}
+		}
+	}
+	return d
+}
+
+type Diff struct {
+	Inserted map[string][]typed.Typed
+	Deleted  map[string][]typed.Typed
+	Updated  map[string][]Change
+}
+
+type Change struct {
+	Before  typed.Typed
+	After   typed.Typed
+	Columns []string
+}
+
+// A row matching kv was inserted into table (columns not in kv are ignored)
+func (d Diff) ExpectInserted(t TB, table string, kv factory.KV) Diff {
+	t.Helper()
+	if !anyMatch(d.Inserted[table], kv) {
+		failure.Report(t, fmt.Sprintf("expected a row inserted in %s matching %v\n%s", table, kv, d))
+	}
+	return d
+}
+
+// A row matching kv was deleted from table (columns not in kv are ignored)
+func (d Diff) ExpectDeleted(t TB, table string, kv factory.KV) Diff {
+	t.Helper()
+	if !anyMatch(d.Deleted[table], kv) {
+		failure.Report(t, fmt.Sprintf("expected a row deleted from %s matching %v\n%s", table, kv, d))
+	}
+	return d
+}
+
+// A row of table was updated and now matches kv (columns not in kv are ignored)
+func (d Diff) ExpectUpdated(t TB, table string, kv factory.KV) Diff {
+	t.Helper()
+	for _, c := range d.Updated[table] {
+		if matches(c.After, kv) {
+			return d
+		}
+	}
+	failure.Report(t, fmt.Sprintf("expected a row updated in %s matching %v\n%s", table, kv, d))
+	return d
+}
+
+// Nothing changed in the given tables (or in any table if none are given)
+func (d Diff) ExpectUnchanged(t TB, tables ...string) Diff {
+	t.Helper()
+	if len(tables) == 0 {
+		if !d.empty() {
+			failure.Report(t, fmt.Sprintf("expected no changes\n%s", d))
+		}
+		return d
+	}
+	for _, table := range tables {
+		if len(d.Inserted[table])+len(d.Deleted[table])+len(d.Updated[table]) > 0 {
+			failure.Report(t, fmt.Sprintf("expected no changes to %s\n%s", table, d))
+			return d
+		}
+	}
+	return d
+}
+
+func (d Diff) String() string {
+	if d.empty() {
+		return "no changes"
+	}
+
+	sb := strings.Builder{}
+	for _, table := range d.tables() {
+		for _, row := range d.Inserted[table] {
+			fmt.Fprintf(&sb, "+ %s %v\n", table, row)
+		}
+		for _, row := range d.Deleted[table] {
+			fmt.Fprintf(&sb, "- %s %v\n", table, row)
+		}
+		for _, c := range d.Updated[table] {
+			fmt.Fprintf(&sb, "~ %s %v\n", table, c.Before)
+			for _, column := range c.Columns {
+				fmt.Fprintf(&sb, "    %s: '%v' -> '%v'\n", column, c.Before[column], c.After[column])
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (d Diff) empty() bool {
+	return len(d.Inserted) == 0 && len(d.Deleted) == 0 && len(d.Updated) == 0
+}
+
+func (d Diff) tables() []string {
+	seen := make(map[string]bool)
+	for _, m := range []map[string][]typed.Typed{d.Inserted, d.Deleted} {
+		for table := range m {
+			seen[table] = true
+		}
+	}
+	for table := range d.Updated {
+		seen[table] = true
+	}
+
+	tables := make([]string, 0, len(seen))
+	for table := range seen {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func read(db TestableDB, name string, pks []string) *tableRows {
+	rows := Rows(db, "select * from "+name)
+	s := &tableRows{
+		name: name,
+		pks:  pks,
+		rows: make(map[string]typed.Typed, len(rows)),
+		keys: make([]string, 0, len(rows)),
+	}
+
+	// without pks, identical rows are told apart by their position among
+	// themselves, so that adding (or removing) a duplicate is a change
+	var seen map[string]int
+	if len(pks) == 0 {
+		seen = make(map[string]int)
+	}
+
+	for _, row := range rows {
+		key := rowKey(row, pks)
+		if seen != nil {
+			n := seen[key]
+			seen[key] = n + 1
+			key += "\x00#" + strconv.Itoa(n)
+		}
+		if _, exists := s.rows[key]; !exists {
+			s.keys = append(s.keys, key)
+		}
+		s.rows[key] = row
+	}
+	return s
+}
+
+func rowKey(row typed.Typed, pks []string) string {
+	columns := pks
+	if len(columns) == 0 {
+		columns = make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+	}
+
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = fmt.Sprintf("%v", row[column])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func changed(before typed.Typed, after typed.Typed) []string {
+	var columns []string
+	for column, value := range after {
+		if !compare.Loose(before[column], value) {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func anyMatch(rows []typed.Typed, kv factory.KV) bool {
+	for _, row := range rows {
+		if matches(row, kv) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(row typed.Typed, kv factory.KV) bool {
+	for k, v := range kv {
+		if !compare.Loose(row[k], v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"src.sqlkite.com/tests/factory"
+	"src.sqlkite.com/utils/typed"
+)
+
+// A single sqlite table, with or without a primary key on id
+type snapshotDB struct {
+	pk   bool
+	rows []typed.Typed
+}
+
+func (db *snapshotDB) Placeholder(i int) string  { return "?" }
+func (db *snapshotDB) IsNotFound(err error) bool { return false }
+func (db *snapshotDB) RowToMap(sql string, args ...any) (typed.Typed, error) {
+	return nil, nil
+}
+func (db *snapshotDB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	if strings.Contains(sql, "pragma_table_info") {
+		pk := 0
+		if db.pk {
+			pk = 1
+		}
+		return []typed.Typed{
+			{"name": "id", "type": "integer", "not_null": 1, "has_default": 0, "pk": pk},
+			{"name": "name", "type": "text", "not_null": 0, "has_default": 0, "pk": 0},
+		}, nil
+	}
+	rows := make([]typed.Typed, len(db.rows))
+	copy(rows, db.rows)
+	return rows, nil
+}
+
+func TestSnapshotWithPK(t *testing.T) {
+	db := &snapshotDB{pk: true, rows: []typed.Typed{
+		{"id": 1, "name": "leto"},
+		{"id": 2, "name": "ghanima"},
+	}}
+	s := Snapshot(db, "users")
+	db.rows = []typed.Typed{
+		{"id": 1, "name": "leto ii"},
+		{"id": 3, "name": "paul"},
+	}
+
+	d := s.Diff()
+	d.ExpectUpdated(t, "users", factory.KV{"id": 1, "name": "leto ii"}).
+		ExpectInserted(t, "users", factory.KV{"id": 3}).
+		ExpectDeleted(t, "users", factory.KV{"id": 2})
+
+	if len(d.Updated["users"]) != 1 || len(d.Inserted["users"]) != 1 || len(d.Deleted["users"]) != 1 {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+	if c := d.Updated["users"][0]; len(c.Columns) != 1 || c.Columns[0] != "name" {
+		t.Fatalf("expected only name to change, got %v", c.Columns)
+	}
+}
+
+func TestSnapshotWithoutPKCountsDuplicates(t *testing.T) {
+	db := &snapshotDB{rows: []typed.Typed{
+		{"id": 1, "name": "leto"},
+	}}
+	s := Snapshot(db, "users")
+
+	db.rows = append(db.rows, typed.Typed{"id": 1, "name": "leto"})
+	d := s.Diff()
+	if len(d.Inserted["users"]) != 1 || len(d.Deleted["users"]) != 0 {
+		t.Fatalf("expected the duplicate to be inserted, got:\n%s", d)
+	}
+
+	s = Snapshot(db, "users")
+	db.rows = db.rows[:1]
+	d = s.Diff()
+	if len(d.Deleted["users"]) != 1 || len(d.Inserted["users"]) != 0 {
+		t.Fatalf("expected the duplicate to be deleted, got:\n%s", d)
+	}
+
+	s = Snapshot(db, "users")
+	s.Diff().ExpectUnchanged(t)
+}
@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A placeholder found in a SQL statement
+type param struct {
+	start int
+	end   int
+	// $N or ?N, 0 for ? and :name
+	number int
+	// :name (or @name)
+	name string
+}
+
+// Which placeholders scan looks for. $N always is.
+type syntax struct {
+	// ? and ?N, which are jsonb operators in postgres
+	question bool
+	// :name, which is array slice syntax in postgres
+	colon bool
+	// @name, which is an operator prefix in postgres
+	at bool
+}
+
+// Rewrites the placeholders in sql for db's dialect, as given by
+// db.Placeholder. The placeholders can be $N and, if db's own placeholders
+// start with ? (so that ? can't be an operator), ?N, ? or :name. Numbered
+// ones refer to that argument (1-based), others to the next argument (after
+// the highest numbered one), with a repeated :name using the same argument.
+// If db's placeholders aren't numbered (Placeholder(0) == Placeholder(1)),
+// args are reordered (and repeated) to match.
+// Anything inside string literals, quoted identifiers, comments and
+// dollar-quoted strings is left alone. For postgres/cockroach ($N), sql is
+// unchanged.
+func translate(db TestableDB, sql string, args []any) (string, []any) {
+	question := usesQuestion(db)
+	params := scan(sql, syntax{question: question, colon: question})
+	if len(params) == 0 {
+		return sql, args
+	}
+
+	// unnumbered placeholders take the arguments after the numbered ones,
+	// so that "$1, :b" doesn't use the first argument twice
+	next := 0
+	for _, p := range params {
+		if p.number > next {
+			next = p.number
+		}
+	}
+
+	indexes := make([]int, len(params))
+	names := make(map[string]int)
+	for i, p := range params {
+		switch {
+		case p.number > 0:
+			indexes[i] = p.number - 1
+		case p.name != "":
+			index, exists := names[p.name]
+			if !exists {
+				index = next
+				names[p.name] = index
+				next++
+			}
+			indexes[i] = index
+		default:
+			indexes[i] = next
+			next++
+		}
+	}
+	return rewrite(db, sql, params, indexes, args)
+}
+
+// Replaces each params[i] with the placeholder for indexes[i]
+func rewrite(db TestableDB, sql string, params []param, indexes []int, args []any) (string, []any) {
+	numbered := db.Placeholder(0) != db.Placeholder(1)
+
+	var reordered []any
+	if !numbered {
+		reordered = make([]any, len(params))
+	}
+
+	sb := strings.Builder{}
+	sb.Grow(len(sql) + len(params)*2)
+
+	position := 0
+	for i, p := range params {
+		sb.WriteString(sql[position:p.start])
+		index := indexes[i]
+		if numbered {
+			sb.WriteString(db.Placeholder(index))
+		} else {
+			sb.WriteString(db.Placeholder(i))
+			if index < len(args) {
+				reordered[i] = args[index]
+			}
+		}
+		position = p.end
+	}
+	sb.WriteString(sql[position:])
+
+	if numbered {
+		return sb.String(), args
+	}
+	return sb.String(), reordered
+}
+
+func usesQuestion(db TestableDB) bool {
+	return strings.HasPrefix(db.Placeholder(0), "?")
+}
+
+// Finds the placeholders in sql
+func scan(sql string, s syntax) []param {
+	var params []param
+
+	l := len(sql)
+	for i := 0; i < l; i++ {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			// E'..' strings support backslash escapes
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentifier(sql[i-2]))
+			i = skipQuoted(sql, i, '\'', escapes)
+		case c == '"' || c == '`':
+			i = skipQuoted(sql, i, c, false)
+		case c == '-' && i+1 < l && sql[i+1] == '-':
+			if end := strings.IndexByte(sql[i:], '\n'); end == -1 {
+				i = l
+			} else {
+				i += end
+			}
+		case c == '/' && i+1 < l && sql[i+1] == '*':
+			i = skipComment(sql, i)
+		case c == '$':
+			if i > 0 && isIdentifier(sql[i-1]) {
+				// postgres allows $ within an identifier
+				continue
+			}
+			if end := digits(sql, i+1); end > i+1 {
+				n, _ := strconv.Atoi(sql[i+1 : end])
+				params = append(params, param{start: i, end: end, number: n})
+				i = end - 1
+			} else if tag, ok := dollarTag(sql, i); ok {
+				if end := strings.Index(sql[i+len(tag):], tag); end == -1 {
+					i = l
+				} else {
+					i += len(tag) + end + len(tag) - 1
+				}
+			}
+		case c == '?' && s.question:
+			end := digits(sql, i+1)
+			n, _ := strconv.Atoi(sql[i+1 : end])
+			params = append(params, param{start: i, end: end, number: n})
+			i = end - 1
+		case (c == ':' && s.colon) || (c == '@' && s.at):
+			// skip postgres' :: casts
+			if c == ':' && ((i > 0 && sql[i-1] == ':') || (i+1 < l && sql[i+1] == ':')) {
+				continue
+			}
+			if i+1 < l && isIdentifierStart(sql[i+1]) {
+				end := i + 2
+				for end < l && isIdentifier(sql[end]) {
+					end++
+				}
+				params = append(params, param{start: i, end: end, name: sql[i+1 : end]})
+				i = end - 1
+			}
+		}
+	}
+	return params
+}
+
+// Returns the index of the closing quote (or the end of sql). A doubled
+// quote is an escaped quote.
+func skipQuoted(sql string, start int, quote byte, escapes bool) int {
+	l := len(sql)
+	for i := start + 1; i < l; i++ {
+		switch sql[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < l && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return l
+}
+
+// Returns the index of the comment's closing '/'. Postgres comments nest.
+func skipComment(sql string, start int) int {
+	depth := 0
+	l := len(sql)
+	for i := start; i < l-1; i++ {
+		if sql[i] == '/' && sql[i+1] == '*' {
+			depth++
+			i++
+		} else if sql[i] == '*' && sql[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return l
+}
+
+// $$ or $tag$
+func dollarTag(sql string, start int) (string, bool) {
+	i := start + 1
+	if i < len(sql) && isIdentifierStart(sql[i]) {
+		for i < len(sql) && isIdentifier(sql[i]) {
+			i++
+		}
+	}
+	if i < len(sql) && sql[i] == '$' {
+		return sql[start : i+1], true
+	}
+	return "", false
+}
+
+// The index after the digits starting at start
+func digits(sql string, start int) int {
+	i := start
+	for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentifier(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
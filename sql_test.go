@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"src.sqlkite.com/utils/typed"
+)
+
+// A TestableDB which only knows its placeholders
+type dialect func(i int) string
+
+func (d dialect) Placeholder(i int) string                              { return d(i) }
+func (d dialect) IsNotFound(err error) bool                             { return false }
+func (d dialect) RowToMap(sql string, args ...any) (typed.Typed, error) { return nil, nil }
+func (d dialect) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	return nil, nil
+}
+
+var (
+	postgres   = dialect(func(i int) string { return fmt.Sprintf("$%d", i+1) })
+	sqlite     = dialect(func(i int) string { return fmt.Sprintf("?%d", i+1) })
+	unnumbered = dialect(func(i int) string { return "?" })
+	mssql      = dialect(func(i int) string { return fmt.Sprintf("@p%d", i+1) })
+)
+
+func TestTranslate(t *testing.T) {
+	cases := []struct {
+		name     string
+		db       dialect
+		sql      string
+		args     []any
+		expected string
+		// nil means args are unchanged
+		expectedArgs []any
+	}{
+		// postgres: nothing but $N is a placeholder, and $N stays $N
+		{name: "pg $N", db: postgres, sql: "select * from x where id = $1 and b = $2", expected: "select * from x where id = $1 and b = $2"},
+		{name: "pg jsonb ?", db: postgres, sql: "select * from x where data ? 'k' and id = $1", expected: "select * from x where data ? 'k' and id = $1"},
+		{name: "pg jsonb ?| and ?&", db: postgres, sql: "select data ?| array['a'], data ?& array['b'] from x", expected: "select data ?| array['a'], data ?& array['b'] from x"},
+		{name: "pg slice", db: postgres, sql: "select arr[lo:hi] from x where id = $1", expected: "select arr[lo:hi] from x where id = $1"},
+		{name: "pg cast", db: postgres, sql: "select $1::int", expected: "select $1::int"},
+		{name: "pg @ operator", db: postgres, sql: "select @x from t", expected: "select @x from t"},
+
+		// sqlite
+		{name: "sqlite $N", db: sqlite, sql: "select * from x where id = $1 and b = $2", expected: "select * from x where id = ?1 and b = ?2"},
+		{name: "sqlite $10", db: sqlite, sql: "select $10, $1", expected: "select ?10, ?1"},
+		{name: "sqlite string", db: sqlite, sql: "select '$1', $1", expected: "select '$1', ?1"},
+		{name: "sqlite doubled quote", db: sqlite, sql: "select 'it''s $1', $1", expected: "select 'it''s $1', ?1"},
+		{name: "sqlite E string", db: sqlite, sql: `select E'it\'s $1', $1`, expected: `select E'it\'s $1', ?1`},
+		{name: "backslash in a normal string", db: sqlite, sql: `select 'a\', $1`, expected: `select 'a\', ?1`},
+		{name: "sqlite quoted identifiers", db: sqlite, sql: "select \"$1\", `$2`, $1", expected: "select \"$1\", `$2`, ?1"},
+		{name: "sqlite line comment", db: sqlite, sql: "select $1 -- $2\n, $3", expected: "select ?1 -- $2\n, ?3"},
+		{name: "sqlite trailing comment", db: sqlite, sql: "select $1 -- $2", expected: "select ?1 -- $2"},
+		{name: "sqlite nested comment", db: sqlite, sql: "select /* $1 /* $2 */ $3 */ $1", expected: "select /* $1 /* $2 */ $3 */ ?1"},
+		{name: "sqlite dollar quotes", db: sqlite, sql: "select $$ $1 $$, $tag$ $2 $tag$, $1", expected: "select $$ $1 $$, $tag$ $2 $tag$, ?1"},
+		{name: "sqlite $ in identifier", db: sqlite, sql: "select a$1 from t where b = $1", expected: "select a$1 from t where b = ?1"},
+		{name: "sqlite ?N", db: sqlite, sql: "select ?2, ?1", expected: "select ?2, ?1"},
+		{name: "sqlite ?", db: sqlite, sql: "select ?, ?", expected: "select ?1, ?2"},
+		{name: "sqlite :name", db: sqlite, sql: "select :a, :b, :a", expected: "select ?1, ?2, ?1"},
+		{name: "sqlite mixed", db: sqlite, sql: "select $1, :b, ?, :b", expected: "select ?1, ?2, ?3, ?2"},
+		{name: "sqlite ::", db: sqlite, sql: "select x::text, :a", expected: "select x::text, ?1"},
+		{name: "sqlite @ isn't a placeholder", db: sqlite, sql: "select @a", expected: "select @a"},
+		{name: "unterminated string", db: sqlite, sql: "select $1, 'abc $2", expected: "select ?1, 'abc $2"},
+
+		// unnumbered, args are reordered
+		{name: "unnumbered $N", db: unnumbered, sql: "select $2, $1, $2", args: []any{"a", "b"}, expected: "select ?, ?, ?", expectedArgs: []any{"b", "a", "b"}},
+		{name: "unnumbered :name", db: unnumbered, sql: "select :x, :y, :x", args: []any{1, 2}, expected: "select ?, ?, ?", expectedArgs: []any{1, 2, 1}},
+		{name: "unnumbered mixed", db: unnumbered, sql: "select $2, :x", args: []any{1, 2, 3}, expected: "select ?, ?", expectedArgs: []any{2, 3}},
+		{name: "unnumbered ?", db: unnumbered, sql: "select ?, ?", args: []any{1, 2}, expected: "select ?, ?", expectedArgs: []any{1, 2}},
+
+		// a dialect which doesn't use ?
+		{name: "mssql $N", db: mssql, sql: "select $1, $2", expected: "select @p1, @p2"},
+		{name: "mssql ?", db: mssql, sql: "select ? from t", expected: "select ? from t"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if args == nil {
+				args = []any{1, 2, 3}
+			}
+			sql, actualArgs := translate(tc.db, tc.sql, args)
+			if sql != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, sql)
+			}
+			expectedArgs := tc.expectedArgs
+			if expectedArgs == nil {
+				expectedArgs = args
+			}
+			if !reflect.DeepEqual(actualArgs, expectedArgs) {
+				t.Fatalf("expected args %v, got %v", expectedArgs, actualArgs)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	all := syntax{question: true, colon: true, at: true}
+	cases := []struct {
+		sql      string
+		s        syntax
+		expected []param
+	}{
+		{sql: "select 1", s: all, expected: nil},
+		{sql: "$1 ?2 ? :a @b", s: all, expected: []param{
+			{start: 0, end: 2, number: 1},
+			{start: 3, end: 5, number: 2},
+			{start: 6, end: 7},
+			{start: 8, end: 10, name: "a"},
+			{start: 11, end: 13, name: "b"},
+		}},
+		{sql: "$1 ?2 ? :a @b", s: syntax{}, expected: []param{
+			{start: 0, end: 2, number: 1},
+		}},
+		{sql: "a ? b", s: syntax{colon: true, at: true}, expected: nil},
+		{sql: "x[1:2]", s: syntax{question: true}, expected: nil},
+		{sql: "$", s: all, expected: nil},
+		{sql: ":", s: all, expected: nil},
+	}
+
+	for _, tc := range cases {
+		actual := scan(tc.sql, tc.s)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.sql, tc.expected, actual)
+		}
+	}
+}
+
+func TestNamed(t *testing.T) {
+	cases := []struct {
+		name         string
+		db           dialect
+		sql          string
+		expected     string
+		expectedArgs []any
+	}{
+		{name: "pg", db: postgres, sql: "select :id, @name, :id", expected: "select $1, $2, $1", expectedArgs: []any{1, "leto"}},
+		{name: "pg cast", db: postgres, sql: "select :id::int", expected: "select $1::int", expectedArgs: []any{1}},
+		{name: "pg jsonb ?", db: postgres, sql: "select data ? 'k' from t where id = :id", expected: "select data ? 'k' from t where id = $1", expectedArgs: []any{1}},
+		{name: "sqlite", db: sqlite, sql: "select :name, ':id', :id", expected: "select ?1, ':id', ?2", expectedArgs: []any{"leto", 1}},
+		{name: "unnumbered", db: unnumbered, sql: "select :id, :name, :id", expected: "select ?, ?, ?", expectedArgs: []any{1, "leto", 1}},
+	}
+
+	params := map[string]any{"id": 1, "name": "leto"}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args := named(tc.db, tc.sql, params)
+			if sql != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, sql)
+			}
+			if !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Fatalf("expected args %v, got %v", tc.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestNamedPanics(t *testing.T) {
+	cases := map[string]string{
+		"mixed":   "select :id, ?",
+		"missing": "select :nope",
+	}
+	for name, sql := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			named(sqlite, sql, map[string]any{"id": 1})
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.sqlkite.com/utils/typed"
+)
+
+// Formats sqlite (which has no time type) and cockroach/postgres (when cast to
+// text) might give us a time as
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Like Row, but the columns are mapped onto the fields of T (by their `db`
+// tag or, if untagged, their name). Values are converted to the field's
+// type, so an int64 column can go into an int field, and a sqlite text or
+// integer column into a time.Time. Returns nil if there's no row.
+func RowAs[T any](db TestableDB, sql string, args ...any) *T {
+	row := Row(db, sql, args...)
+	if row == nil {
+		return nil
+	}
+	var value T
+	mapRow(row, reflect.ValueOf(&value).Elem())
+	return &value
+}
+
+// Like Rows, but each row is mapped onto a T (see RowAs)
+func RowsAs[T any](db TestableDB, sql string, args ...any) []T {
+	rows := Rows(db, sql, args...)
+	values := make([]T, len(rows))
+	for i, row := range rows {
+		mapRow(row, reflect.ValueOf(&values[i]).Elem())
+	}
+	return values
+}
+
+func mapRow(row typed.Typed, v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		panic("expected a struct, got " + v.Kind().String())
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := columnName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, exists := row[name]
+		if !exists {
+			continue
+		}
+		if err := assign(v.Field(i), value); err != nil {
+			panic(fmt.Sprintf("column %s: %v", name, err))
+		}
+	}
+}
+
+func assign(field reflect.Value, value any) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := assign(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if field.Type() == timeType {
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(v)
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, field.Type())
+		}
+		field.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toUint(v)
+		if err != nil {
+			return err
+		}
+		if field.OverflowUint(n) {
+			return fmt.Errorf("%d overflows %s", n, field.Type())
+		}
+		field.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := toFloat(v)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		b, err := toBool(v)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+		return nil
+	case reflect.String:
+		if b, ok := value.([]byte); ok {
+			field.SetString(string(b))
+		} else {
+			field.SetString(fmt.Sprint(value))
+		}
+		return nil
+	}
+
+	if v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+		return nil
+	}
+	if s, ok := value.(string); ok && field.Type() == reflect.TypeOf([]byte(nil)) {
+		field.SetBytes([]byte(s))
+		return nil
+	}
+
+	// json/jsonb columns
+	var data []byte
+	switch j := value.(type) {
+	case string:
+		data = []byte(j)
+	case []byte:
+		data = j
+	}
+	if data != nil {
+		return json.Unmarshal(data, field.Addr().Interface())
+	}
+
+	if v.Type().ConvertibleTo(field.Type()) {
+		field.Set(v.Convert(field.Type()))
+		return nil
+	}
+	return fmt.Errorf("can't assign %T to %s", value, field.Type())
+}
+
+// Integers are never converted through a float64, which can't hold every
+// int64 (like cockroach's unique_rowid() ids)
+func toInt(v reflect.Value) (int64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", n)
+		}
+		return int64(n), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v isn't an integer", f)
+		}
+		return int64(f), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parseInt(v.String())
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		return parseInt(string(b))
+	}
+	return 0, fmt.Errorf("can't convert %s to an integer", v.Type())
+}
+
+func toUint(v reflect.Value) (uint64, error) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.String:
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	n, err := toInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%d is negative", n)
+	}
+	return uint64(n), nil
+}
+
+// Also accepts an integral float ("3.0"), which is how some drivers give
+// us numeric columns
+func parseInt(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return toInt(reflect.ValueOf(f))
+}
+
+func toFloat(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return strconv.ParseFloat(v.String(), 64)
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		return strconv.ParseFloat(string(b), 64)
+	}
+	return 0, fmt.Errorf("can't convert %s to a number", v.Type())
+}
+
+func toBool(v reflect.Value) (bool, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		switch strings.ToLower(v.String()) {
+		case "t", "true", "1":
+			return true, nil
+		case "f", "false", "0":
+			return false, nil
+		}
+		return false, fmt.Errorf("can't convert %q to a bool", v.String())
+	}
+	n, err := toFloat(v)
+	return n != 0, err
+}
+
+func toTime(value any) (time.Time, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case int:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case float64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case []byte:
+		return toTime(string(t))
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("can't parse %q as a time", t)
+	}
+	return time.Time{}, fmt.Errorf("can't convert %T to a time", value)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAssignIntegers(t *testing.T) {
+	type row struct {
+		ID    int64
+		Small int8
+		U     uint64
+		F     float64
+	}
+
+	cases := []struct {
+		column   string
+		value    any
+		expected any
+	}{
+		{column: "ID", value: int64(812345678901234567), expected: int64(812345678901234567)},
+		{column: "ID", value: "812345678901234567", expected: int64(812345678901234567)},
+		{column: "ID", value: []byte("812345678901234567"), expected: int64(812345678901234567)},
+		{column: "ID", value: int64(math.MinInt64), expected: int64(math.MinInt64)},
+		{column: "ID", value: uint64(12), expected: int64(12)},
+		{column: "ID", value: float64(3), expected: int64(3)},
+		{column: "ID", value: "3.0", expected: int64(3)},
+		{column: "ID", value: true, expected: int64(1)},
+		{column: "U", value: uint64(math.MaxUint64), expected: uint64(math.MaxUint64)},
+		{column: "U", value: "18446744073709551615", expected: uint64(math.MaxUint64)},
+		{column: "U", value: int64(812345678901234567), expected: uint64(812345678901234567)},
+		{column: "Small", value: int64(-128), expected: int8(-128)},
+		{column: "F", value: int64(3), expected: float64(3)},
+	}
+	for _, tc := range cases {
+		var r row
+		field := reflect.ValueOf(&r).Elem().FieldByName(tc.column)
+		if err := assign(field, tc.value); err != nil {
+			t.Errorf("%s = %#v: %v", tc.column, tc.value, err)
+			continue
+		}
+		if actual := field.Interface(); actual != tc.expected {
+			t.Errorf("%s = %#v: expected %#v, got %#v", tc.column, tc.value, tc.expected, actual)
+		}
+	}
+}
+
+func TestAssignIntegerErrors(t *testing.T) {
+	type row struct {
+		ID    int64
+		Small int8
+		U     uint64
+	}
+
+	cases := []struct {
+		column string
+		value  any
+	}{
+		{column: "Small", value: int64(128)},
+		{column: "ID", value: uint64(math.MaxUint64)},
+		{column: "ID", value: 1.5},
+		{column: "ID", value: "nope"},
+		{column: "U", value: int64(-1)},
+		{column: "U", value: "-1"},
+	}
+	for _, tc := range cases {
+		var r row
+		field := reflect.ValueOf(&r).Elem().FieldByName(tc.column)
+		if err := assign(field, tc.value); err == nil {
+			t.Errorf("%s = %#v: expected an error, got %#v", tc.column, tc.value, field.Interface())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Creates and drops the postgres/cockroach temporary databases, so it
+// should be connected to PG() or CR(). Not used for sqlite (can be nil).
+type Admin interface {
+	MustExec(sql string, args ...any)
+}
+
+// Creates a uniquely named database for StorageType(): a file in a temp
+// directory for sqlite, a database for postgres and cockroach. migrate is
+// called with the new database's connection string. Returns the connection
+// string and a function which drops the database.
+// Meant to be used from TestMain for a package-wide database, see TempDB
+// for a per-test database.
+func NewTempDB(admin Admin, migrate func(conn string)) (string, func()) {
+	name := "sqlkite_test_" + strings.ReplaceAll(uuid.Must(uuid.NewRandom()).String(), "-", "")
+
+	var conn string
+	var drop func()
+
+	switch StorageType() {
+	case "sqlite":
+		dir, err := os.MkdirTemp("", name)
+		if err != nil {
+			panic(err)
+		}
+		conn = filepath.Join(dir, "sqlkite.db")
+		drop = func() {
+			os.RemoveAll(dir)
+		}
+	case "postgres":
+		admin.MustExec("create database " + name)
+		conn = pgServer() + "/" + name
+		drop = func() {
+			admin.MustExec("drop database if exists " + name + " with (force)")
+		}
+	case "cockroach":
+		admin.MustExec("create database " + name)
+		conn = crServer() + "/" + name
+		drop = func() {
+			admin.MustExec("drop database if exists " + name + " cascade")
+		}
+	}
+
+	if migrate != nil {
+		func() {
+			// don't leave the database behind if the migration panics
+			defer func() {
+				if r := recover(); r != nil {
+					drop()
+					panic(r)
+				}
+			}()
+			migrate(conn)
+		}()
+	}
+	return conn, drop
+}
+
+// NewTempDB for a single test, dropped when the test ends
+func TempDB(t *testing.T, admin Admin, migrate func(conn string)) string {
+	conn, drop := NewTempDB(admin, migrate)
+	t.Cleanup(drop)
+	return conn
+}
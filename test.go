@@ -1,16 +1,27 @@
 package tests
 
 import (
+	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"testing"
+	"time"
 
+	"src.sqlkite.com/tests/internal/failure"
 	"src.sqlkite.com/utils/log"
 	"src.sqlkite.com/utils/typed"
 )
 
+// Deprecated: Row and Rows now translate placeholders (see translate)
 var PlaceholderPattern = regexp.MustCompile(`\$(\d+)`)
 
+// The part of *testing.T that the expectations (like Diff.ExpectInserted)
+// need. The same type as assert.TB.
+type TB = failure.TB
+
 type TestableDB interface {
 	Placeholder(i int) string
 	IsNotFound(err error) bool
@@ -19,10 +30,7 @@ type TestableDB interface {
 }
 
 func Row(db TestableDB, sql string, args ...any) typed.Typed {
-	// no one's going to like this, but not sure how else to deal with it
-	if db.Placeholder(0) == "?1" {
-		sql = PlaceholderPattern.ReplaceAllString(sql, "?$1")
-	}
+	sql, args = translate(db, sql, args)
 	row, err := db.RowToMap(sql, args...)
 	if err != nil {
 		if db.IsNotFound(err) {
@@ -34,10 +42,7 @@ func Row(db TestableDB, sql string, args ...any) typed.Typed {
 }
 
 func Rows(db TestableDB, sql string, args ...any) []typed.Typed {
-	// no one's going to like this, but not sure how else to deal with it
-	if db.Placeholder(0) == "?1" {
-		sql = PlaceholderPattern.ReplaceAllString(sql, "?$1")
-	}
+	sql, args = translate(db, sql, args)
 	rows, err := db.RowsToMap(sql, args...)
 	if err != nil {
 		panic(err)
@@ -46,19 +51,27 @@ func Rows(db TestableDB, sql string, args ...any) []typed.Typed {
 }
 
 func PG() string {
+	return pgServer() + "/sqlkite_test"
+}
+
+func CR() string {
+	return crServer() + "/sqlkite_test"
+}
+
+func pgServer() string {
 	pg := os.Getenv("SQLKITE_TEST_PG")
 	if pg == "" {
 		pg = "postgres://localhost:5432"
 	}
-	return pg + "/sqlkite_test"
+	return pg
 }
 
-func CR() string {
+func crServer() string {
 	cr := os.Getenv("SQLKITE_TEST_CR")
 	if cr == "" {
 		cr = "postgres://root@localhost:26257"
 	}
-	return cr + "/sqlkite_test"
+	return cr
 }
 
 func StorageType() string {
@@ -75,6 +88,72 @@ func StorageType() string {
 	}
 }
 
+// Runs fn as a subtest for each configured storage (SQLKITE_TEST_STORAGES,
+// a comma separated list, defaults to all of them). Storages which can't be
+// reached are skipped, but an unknown storage panics (so that a typo doesn't
+// quietly skip it). SQLKITE_TEST_STORAGE is set for each subtest, so
+// StorageType() returns the storage being tested (and thus the subtests
+// can't be parallel).
+func ForEachStorage(t *testing.T, fn func(t *testing.T, storage string)) {
+	storages := []string{"sqlite", "postgres", "cockroach"}
+	if env := os.Getenv("SQLKITE_TEST_STORAGES"); env != "" {
+		storages = strings.Split(env, ",")
+		for i, storage := range storages {
+			storage = strings.ToLower(strings.TrimSpace(storage))
+			switch storage {
+			case "sqlite", "postgres", "cockroach":
+				storages[i] = storage
+			default:
+				panic(fmt.Sprintf("Unknown SQLKITE_TEST_STORAGES value %q. Should be a comma separated list of: sqlite, postgres, cockroach", storage))
+			}
+		}
+	}
+
+	for _, storage := range storages {
+		t.Run(storage, func(t *testing.T) {
+			if err := reachable(storage); err != nil {
+				t.Skipf("skipping %s, not reachable: %v", storage, err)
+			}
+			t.Setenv("SQLKITE_TEST_STORAGE", storage)
+			fn(t, storage)
+		})
+	}
+}
+
+func reachable(storage string) error {
+	var server string
+	switch storage {
+	case "sqlite":
+		return nil
+	case "postgres":
+		server = pgServer()
+	case "cockroach":
+		server = crServer()
+	default:
+		return fmt.Errorf("unknown storage %q", storage)
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if storage == "postgres" {
+			host += ":5432"
+		} else {
+			host += ":26257"
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", host, time.Second)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
 func CaptureLog(fn func()) string {
 	defer func() {
 		log.Out = os.Stderr